@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -577,3 +578,77 @@ func TestLoadWithNoUserProfilesDirStillWorks(t *testing.T) {
 		t.Fatalf("expected source 'default', got %q", sources[0])
 	}
 }
+
+func TestSanitizeProfileForSaveKeepsNestedConditionGroups(t *testing.T) {
+	threshold := float32(20)
+	profile := pkg.Profile{
+		Metadata: &pkg.Metadata{Name: "B350"},
+		Leds: &pkg.Leds{
+			FUEL_LOW_PRESSURE: pkg.LEDProfile{
+				ConditionProfile: pkg.ConditionProfile{
+					Datarefs: []pkg.DatarefCondition{
+						{DatarefStr: "sim/cockpit/electrical/battery_on", Operator: "==", Threshold: new(float32)},
+					},
+					Groups: []pkg.ConditionProfile{
+						{
+							Condition: "any",
+							Datarefs: []pkg.DatarefCondition{
+								{DatarefStr: "sim/cockpit2/engine/indicators/fuel_pressure_psi", Index: 0, Operator: "<", Threshold: &threshold},
+								{DatarefStr: "sim/cockpit2/engine/indicators/fuel_pressure_psi", Index: 1, Operator: "<", Threshold: &threshold},
+							},
+						},
+					},
+					Condition: "all",
+				},
+			},
+		},
+	}
+
+	cleanProfile, err := sanitizeProfileForSave(profile)
+	if err != nil {
+		t.Fatalf("sanitizeProfileForSave returned error: %v", err)
+	}
+
+	saved, err := yaml.Marshal(cleanProfile)
+	if err != nil {
+		t.Fatalf("failed to marshal profile: %v", err)
+	}
+	var savedProfile pkg.Profile
+	if err := yaml.Unmarshal(saved, &savedProfile); err != nil {
+		t.Fatalf("failed to parse saved profile yaml: %v", err)
+	}
+
+	groups := savedProfile.Leds.FUEL_LOW_PRESSURE.Groups
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 condition group, got %d", len(groups))
+	}
+	if groups[0].Condition != "any" || len(groups[0].Datarefs) != 2 {
+		t.Fatalf("expected an any group with 2 datarefs, got %+v", groups[0])
+	}
+	if groups[0].Datarefs[1].Index != 1 || *groups[0].Datarefs[1].Threshold != 20 {
+		t.Fatalf("expected second group dataref to keep index and threshold, got %+v", groups[0].Datarefs[1])
+	}
+}
+
+func TestApplyImportedKnobsKeepsEncoderRepeat(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("configurator", "Default_Throttle.json"))
+	if err != nil {
+		t.Fatalf("failed to read configurator profile: %v", err)
+	}
+	var oldProfile ConfiguratorProfile
+	if err := json.Unmarshal(content, &oldProfile); err != nil {
+		t.Fatalf("failed to parse configurator profile: %v", err)
+	}
+
+	profile := pkg.Profile{Knobs: &pkg.Knobs{}}
+	applyImportedKnobs(&profile, oldProfile.Data)
+
+	want := []pkg.Command{
+		{CommandStr: "sim/autopilot/altitude_up", Repeat: 3},
+		{CommandStr: "sim/autopilot/altitude_down", Repeat: 3},
+	}
+	got := profile.Knobs.AP_ALT.Commands
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected ap_alt commands %+v, got %+v", want, got)
+	}
+}
@@ -126,16 +126,17 @@ var ledMapping = map[int]string{
 	ledMapKey(4, 3): "doors",
 }
 
-// Bravo hardware button numbers → YAML button names.
+// Bravo hardware button numbers → YAML button names. The numbers are the
+// bits of the input report, the same ones the plugin decodes.
 var apButtonMapping = map[int]string{
-	21: "hdg",
-	22: "nav",
-	23: "apr",
-	24: "rev",
-	25: "alt",
-	26: "vs",
-	27: "ias",
-	28: "ap",
+	0: "hdg",
+	1: "nav",
+	2: "apr",
+	3: "rev",
+	4: "alt",
+	5: "vs",
+	6: "ias",
+	7: "ap",
 }
 
 // FCU_SELECTOR condition values → YAML knob names.
@@ -250,10 +251,11 @@ func findButton(data []ConfiguratorButton, number int) *ConfiguratorButton {
 // extractEncoderKnobCommands parses encoder buttons 12 (up) and 13 (down) to
 // extract per-knob increment/decrement commands from FCU_SELECTOR conditions.
 // Returns a map[knobName] → [incrementCmd, decrementCmd] and any warnings.
-func extractEncoderKnobCommands(data []ConfiguratorButton) (map[string][2]string, []string) {
+// The commands keep the Repeat of their encoder button.
+func extractEncoderKnobCommands(data []ConfiguratorButton) (map[string][2]pkg.Command, []string) {
 	// Collect increment commands from button 12, decrement from button 13.
-	incCmds := map[string]string{} // knob name → increment command
-	decCmds := map[string]string{} // knob name → decrement command
+	incCmds := map[string]pkg.Command{} // knob name → increment command
+	decCmds := map[string]pkg.Command{} // knob name → decrement command
 
 	var warnings []string
 
@@ -288,15 +290,15 @@ func extractEncoderKnobCommands(data []ConfiguratorButton) (map[string][2]string
 				continue
 			}
 			if btnNum == encoderUpButton {
-				incCmds[knobName] = cmd
+				incCmds[knobName] = pkg.Command{CommandStr: cmd, Repeat: event.Repeat}
 			} else {
-				decCmds[knobName] = cmd
+				decCmds[knobName] = pkg.Command{CommandStr: cmd, Repeat: event.Repeat}
 			}
 		}
 	}
 
 	// Pair up increment + decrement into result map.
-	result := map[string][2]string{}
+	result := map[string][2]pkg.Command{}
 	allKnobs := map[string]bool{}
 	for k := range incCmds {
 		allKnobs[k] = true
@@ -306,11 +308,11 @@ func extractEncoderKnobCommands(data []ConfiguratorButton) (map[string][2]string
 	}
 
 	for knob := range allKnobs {
-		inc := incCmds[knob]
-		dec := decCmds[knob]
-		if inc != "" && dec != "" {
-			result[knob] = [2]string{inc, dec}
-		} else if inc != "" {
+		inc, hasInc := incCmds[knob]
+		dec, hasDec := decCmds[knob]
+		if hasInc && hasDec {
+			result[knob] = [2]pkg.Command{inc, dec}
+		} else if hasInc {
 			warnings = append(warnings, fmt.Sprintf("Knob %s: found increment command but no decrement — skipped", knob))
 		} else {
 			warnings = append(warnings, fmt.Sprintf("Knob %s: found decrement command but no increment — skipped", knob))
@@ -633,7 +635,7 @@ func applyImportedButtons(profile *pkg.Profile, data []ConfiguratorButton) {
 		}
 
 		bp := pkg.ButtonProfile{
-			SingleClick: []pkg.Command{{CommandStr: cmd}},
+			SingleClick: []pkg.ButtonAction{{CommandStr: cmd, Repeat: btn.PressEvent[0].Repeat}},
 		}
 
 		switch btnName {
@@ -662,8 +664,8 @@ func applyImportedKnobs(profile *pkg.Profile, data []ConfiguratorButton) {
 
 	for knobName, cmds := range knobCmds {
 		commands := []pkg.Command{
-			{CommandStr: cmds[0]}, // increment
-			{CommandStr: cmds[1]}, // decrement
+			cmds[0], // increment
+			cmds[1], // decrement
 		}
 
 		switch knobName {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func loadDefaultThrottle(t *testing.T) ConfiguratorProfile {
+	content, err := os.ReadFile(filepath.Join("configurator", "Default_Throttle.json"))
+	if err != nil {
+		t.Fatalf("failed to read configurator profile: %v", err)
+	}
+	var oldProfile ConfiguratorProfile
+	if err := json.Unmarshal(content, &oldProfile); err != nil {
+		t.Fatalf("failed to parse configurator profile: %v", err)
+	}
+	return oldProfile
+}
+
+// The configurator numbers buttons by their bit in the input report: its
+// default profile has the encoder on 12/13 and the mode selector on 16-20, so
+// the AP buttons are 0-7 and 21-28 are the trim wheel and reverse detents.
+func TestApplyImportedButtonsUsesInputReportNumbers(t *testing.T) {
+	oldProfile := loadDefaultThrottle(t)
+	for _, number := range []int{0, 7, 21, 28} {
+		btn := findButton(oldProfile.Data, number)
+		if btn == nil {
+			t.Fatalf("button %d not found in configurator profile", number)
+		}
+		btn.PressEvent = []ConfiguratorEvent{{Variable: fmt.Sprintf("sim/test/button_%d", number)}}
+	}
+
+	profile := pkg.Profile{Buttons: &pkg.Buttons{}}
+	applyImportedButtons(&profile, oldProfile.Data)
+
+	if got := profile.Buttons.HDG.SingleClick; len(got) != 1 || got[0].CommandStr != "sim/test/button_0" {
+		t.Fatalf("expected button 0 on hdg, got %+v", got)
+	}
+	if got := profile.Buttons.AP.SingleClick; len(got) != 1 || got[0].CommandStr != "sim/test/button_7" {
+		t.Fatalf("expected button 7 on ap, got %+v", got)
+	}
+	for name, btn := range map[string]pkg.ButtonProfile{"nav": profile.Buttons.NAV, "ias": profile.Buttons.IAS} {
+		if len(btn.SingleClick) != 0 {
+			t.Fatalf("expected no import for %s, got %+v", name, btn.SingleClick)
+		}
+	}
+}
@@ -2,105 +2,203 @@ package honeycomb
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/sstallion/go-hid"
 	"github.com/x-z7a/zoal-honeycomb/pkg"
 	"github.com/xairline/goplane/xplm/utilities"
 )
 
 var Vendor uint16 = 0x294B
 var Product uint16 = 0x1901
-var BRAVO_CONNECTED = true
-var PROFILE_LOADED = false
+var BRAVO_CONNECTED atomic.Bool
+
+// defaultMaxReportRate caps LED feature reports per second. Changes that
+// arrive faster than this are coalesced into a single report.
+const defaultMaxReportRate = 20.0
+
+const ledReportLength = 65
+
+const (
+	// inputReadTimeout bounds a single blocking read so the reader notices
+	// Exit in time.
+	inputReadTimeout = 100 * time.Millisecond
+	// inputQueueSize is how many decoded events may wait for the flight loop
+	// before new ones are dropped.
+	inputQueueSize = 256
+)
 
 type BravoService interface {
 	UpdateLeds()
+	Panel() *PanelState
+	// Input delivers the button and axis events read from the device.
+	Input() <-chan InputEvent
 	Exit()
 }
 
+// BravoOption customizes a bravo service created by NewBravoService or
+// NewBravoServiceWithTransport.
+type BravoOption func(*bravoService)
+
+// WithMaxReportRate limits how many LED reports per second are sent to the
+// device. Values <= 0 fall back to the default.
+func WithMaxReportRate(reportsPerSecond float64) BravoOption {
+	return func(b *bravoService) {
+		if reportsPerSecond <= 0 {
+			reportsPerSecond = defaultMaxReportRate
+		}
+		b.minReportInterval = time.Duration(float64(time.Second) / reportsPerSecond)
+	}
+}
+
 type bravoService struct {
-	Logger pkg.Logger
-	ctx    context.Context
+	Logger    pkg.Logger
+	ctx       context.Context
+	transport Transport
+	conn      *connection
+	panel     *PanelState
 
-	hidReportBuffer []byte
-	cancelFunc      context.CancelFunc
+	hidReportBuffer   []byte
+	minReportInterval time.Duration
+	writerDone        chan struct{}
+	input             chan InputEvent
+	readerDone        chan struct{}
+	connDone          chan struct{}
+	cancelFunc        context.CancelFunc
 }
 
+// UpdateLeds starts the LED writer. It sleeps until the panel state changes
+// or a blinking LED needs to toggle, then writes what should be lit, never
+// faster than the max report rate.
 func (b *bravoService) UpdateLeds() {
+	b.writerDone = make(chan struct{})
 
 	go func() {
+		defer close(b.writerDone)
+
+		var lastWrite time.Time
+		var written LEDState
+		var animation <-chan time.Time
 		for {
 			select {
 			case <-b.ctx.Done():
 				b.Logger.Infof("UpdateLeds: Context canceled, exiting goroutine")
 				return
-			default:
-				if BRAVO_CONNECTED == false || PROFILE_LOADED == false {
-					continue
+			case <-b.panel.changed:
+			case <-animation:
+			}
+
+			// hold back until the rate limit allows the next report, changes
+			// that come in meanwhile are folded into it
+			if wait := b.minReportInterval - time.Since(lastWrite); wait > 0 {
+				select {
+				case <-b.ctx.Done():
+					b.Logger.Infof("UpdateLeds: Context canceled, exiting goroutine")
+					return
+				case <-time.After(wait):
 				}
-				LED_STATE_CHANGED_LOCK.Lock()
-				ledStateChanged := LED_STATE_CHANGED
-				LED_STATE_CHANGED = false
-				LED_STATE_CHANGED_LOCK.Unlock()
-
-				if ledStateChanged {
-					b.Logger.Debugf("LED_STATE_CHANGED: %v", LED_STATE_CHANGED)
-					b.DebugPrintLEDStates()
-					b.hidReportBuffer[0] = 0x0
-					b.hidReportBuffer[1] = AUTO_PILOT_W
-					b.hidReportBuffer[2] = LANDING_GEAR_W
-					b.hidReportBuffer[3] = ANUNCIATOR_W1
-					b.hidReportBuffer[4] = ANUNCIATOR_W2
-					bravo, err := hid.OpenFirst(Vendor, Product)
-					if err != nil || bravo == nil {
-						b.Logger.Errorf("failed to open device: %v", err)
-						continue
-					}
-					x, err := bravo.SendFeatureReport(b.hidReportBuffer)
-					if err != nil {
-						b.Logger.Errorf("failed to write to device: %v", err)
-						b.Logger.Infof("bytes written: %d", x)
-					}
-					b.Logger.Debugf("bytes written: %d", x)
-					if x != 65 {
-						LED_STATE_CHANGED_LOCK.Lock()
-						LED_STATE_CHANGED = true
-						LED_STATE_CHANGED_LOCK.Unlock()
-					}
-					bravo.Close()
-					time.Sleep(100 * time.Millisecond) // Simulated delay
+			}
+
+			_, changed := b.panel.takeDirty()
+			state, next := b.panel.Render(time.Now())
+			animation = nil
+			if next > 0 {
+				animation = time.After(next)
+			}
+			if !changed && state == written {
+				continue
+			}
+
+			if changed {
+				b.Logger.Debugf("LED state changed:%s", b.panel.Snapshot())
+			}
+			state.Report(b.hidReportBuffer)
+			lastWrite = time.Now()
+			if err := b.conn.SendFeatureReport(b.hidReportBuffer); err != nil {
+				if errors.Is(err, errNotConnected) {
+					// the state is resent once the device is back
+					continue
 				}
+				b.Logger.Errorf("failed to write to device: %v", err)
+				b.panel.Invalidate()
+				continue
 			}
+			written = state
 		}
 	}()
 }
 
-func (b *bravoService) Exit() {
-	if BRAVO_CONNECTED == false {
-		return
-	}
-	b.cancelFunc()
+func (b *bravoService) Panel() *PanelState {
+	return b.panel
+}
+
+func (b *bravoService) Input() <-chan InputEvent {
+	return b.input
+}
+
+// readInput decodes input reports into events until the service exits.
+func (b *bravoService) readInput() {
+	defer close(b.readerDone)
 
-	b.hidReportBuffer[0] = 0x0
-	b.hidReportBuffer[1] = 0x0
-	b.hidReportBuffer[2] = 0x0
-	b.hidReportBuffer[3] = 0x0
-	b.hidReportBuffer[4] = 0x0
-	bravo, err := hid.OpenFirst(Vendor, Product)
-	if err != nil {
-		b.Logger.Errorf("failed to open device: %v", err)
+	report := make([]byte, inputReportLength)
+	var decoder inputDecoder
+	var events []InputEvent
+	for {
+		if b.ctx.Err() != nil {
+			return
+		}
+
+		n, err := b.conn.ReadInputReport(report, inputReadTimeout)
+		if err != nil {
+			// start from scratch once the device is back, buttons held while
+			// it was gone are reported as pressed again
+			decoder.reset()
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(reconnectMinBackoff):
+			}
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+
+		events, err = decoder.decode(report[:n], events[:0])
+		if err != nil {
+			b.Logger.Errorf("failed to decode input report: %v", err)
+			continue
+		}
+		for _, event := range events {
+			select {
+			case b.input <- event:
+			default:
+				b.Logger.Warningf("input queue full, dropping event: %s", event)
+			}
+		}
 	}
-	if x, err := bravo.SendFeatureReport(b.hidReportBuffer); err != nil {
-		b.Logger.Errorf("failed to write to device: %v", err)
-		b.Logger.Infof("bytes written: %d", x)
+}
+
+func (b *bravoService) Exit() {
+	b.cancelFunc()
+	if b.writerDone != nil {
+		<-b.writerDone
 	}
+	<-b.readerDone
+	// a reconnect that is still running could reopen the device after Close
+	<-b.connDone
 
-	if err := bravo.Close(); err != nil {
-		b.Logger.Errorf("failed to close device: %v", err)
+	if b.conn.isConnected() {
+		LEDState(0).Report(b.hidReportBuffer)
+		if err := b.conn.SendFeatureReport(b.hidReportBuffer); err != nil {
+			b.Logger.Errorf("failed to write to device: %v", err)
+		}
 	}
-	if err := hid.Exit(); err != nil {
+	b.conn.Close()
+
+	if err := b.transport.Exit(); err != nil {
 		b.Logger.Errorf("failed to exit hidapi: %v", err)
 	}
 }
@@ -108,7 +206,7 @@ func (b *bravoService) Exit() {
 var bravoSvcLock = &sync.Mutex{}
 var bravoSvc BravoService
 
-func NewBravoService(logger pkg.Logger) BravoService {
+func NewBravoService(logger pkg.Logger, opts ...BravoOption) BravoService {
 	if bravoSvc != nil {
 		logger.Info("Bravo SVC has been initialized already")
 		return bravoSvc
@@ -117,30 +215,55 @@ func NewBravoService(logger pkg.Logger) BravoService {
 		bravoSvcLock.Lock()
 		defer bravoSvcLock.Unlock()
 
-		if err := hid.Init(); err != nil {
+		transport, err := NewHIDTransport(Vendor, Product)
+		if err != nil {
 			logger.Errorf("failed to initialize hidapi: %v", err)
 			return nil
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		bravoSvc = &bravoService{
-			Logger:          logger,
-			ctx:             ctx,
-			hidReportBuffer: make([]byte, 65),
-			cancelFunc:      cancel,
-		}
-
-		bravo, err := hid.OpenFirst(Vendor, Product)
-		if err != nil || bravo == nil {
-			logger.Errorf("failed to open device: %v", err)
+		bravoSvc = NewBravoServiceWithTransport(logger, transport, opts...)
+		if !BRAVO_CONNECTED.Load() {
 			utilities.SpeakString("Bravo device not found")
-			BRAVO_CONNECTED = false
 		}
-		if bravo != nil {
-			bravo.Close()
-		}
-
-		bravoSvc.UpdateLeds()
 		return bravoSvc
 	}
 }
+
+// NewBravoServiceWithTransport creates a bravo service that talks to the
+// device through the given transport. Unlike NewBravoService it does not
+// register a singleton, so tests can run one service per fake transport.
+// The device handle stays open until Exit; if the Bravo is missing or goes
+// away it is reopened in the background as soon as it shows up again.
+func NewBravoServiceWithTransport(logger pkg.Logger, transport Transport, opts ...BravoOption) BravoService {
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := &bravoService{
+		Logger:            logger,
+		ctx:               ctx,
+		transport:         transport,
+		hidReportBuffer:   make([]byte, ledReportLength),
+		minReportInterval: time.Duration(float64(time.Second) / defaultMaxReportRate),
+		input:             make(chan InputEvent, inputQueueSize),
+		readerDone:        make(chan struct{}),
+		connDone:          make(chan struct{}),
+		cancelFunc:        cancel,
+		panel:             NewPanelState(),
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	// resend the current LED state whenever the device (re)appears
+	svc.conn = newConnection(logger, transport, svc.panel.Invalidate)
+
+	if !svc.conn.connect() {
+		logger.Errorf("failed to open device, will keep retrying in the background")
+		BRAVO_CONNECTED.Store(false)
+	}
+
+	go func() {
+		defer close(svc.connDone)
+		svc.conn.run(ctx)
+	}()
+	go svc.readInput()
+	svc.UpdateLeds()
+	return svc
+}
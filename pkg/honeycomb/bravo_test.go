@@ -0,0 +1,212 @@
+package honeycomb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateLedsWritesLedStateToDevice(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	var state LEDState
+	state.Set(LED_HEADING, true)
+	state.Set(LED_LEFT_GEAR_GREEN, true)
+	state.Set(LED_MASTER_CAUTION, true)
+	state.Set(LED_DOOR, true)
+	svc.Panel().Apply(state)
+
+	expected := make([]byte, 65)
+	expected[1] = 0x01
+	expected[2] = 0x01
+	expected[3] = 0x20
+	expected[4] = 0x08
+	assert.Eventually(t, func() bool {
+		return bytes.Equal(transport.LastReport(), expected)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestExitTurnsAllLedsOff(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+
+	svc.Exit()
+
+	assert.Equal(t, make([]byte, 65), transport.LastReport())
+}
+
+func TestExitLeavesNoDeviceOpenWhileReconnecting(t *testing.T) {
+	transport := NewFakeTransport()
+	transport.SetOpenError(errors.New("no device"))
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+
+	// plug the device in just as the first reconnect is due
+	time.Sleep(reconnectMinBackoff - 10*time.Millisecond)
+	transport.SetOpenError(nil)
+	svc.Exit()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, 0, transport.OpenDevices())
+}
+
+func TestMissingDeviceMarksBravoDisconnected(t *testing.T) {
+	transport := NewFakeTransport()
+	transport.SetOpenError(errors.New("no device"))
+
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	assert.False(t, BRAVO_CONNECTED.Load())
+	assert.Empty(t, transport.Reports())
+}
+
+func TestUpdateLedsKeepsDeviceOpenBetweenWrites(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	var state LEDState
+	state.Set(LED_AP, true)
+	svc.Panel().Apply(state)
+	assert.Eventually(t, func() bool {
+		report := transport.LastReport()
+		return report != nil && report[1] == 0x80
+	}, time.Second, 10*time.Millisecond)
+	state.Set(LED_ALT, true)
+	svc.Panel().Apply(state)
+	assert.Eventually(t, func() bool {
+		report := transport.LastReport()
+		return report != nil && report[1] == 0x90
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1, transport.Opens())
+}
+
+func TestBravoReconnectsAfterWriteFailure(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	// unplug the device
+	unplugged := errors.New("device unplugged")
+	transport.SetWriteError(unplugged)
+	transport.SetOpenError(unplugged)
+	var state LEDState
+	state.Set(LED_NAV, true)
+	svc.Panel().Apply(state)
+	assert.Eventually(t, func() bool {
+		return !BRAVO_CONNECTED.Load()
+	}, time.Second, 10*time.Millisecond)
+
+	// plug it back in, the current LED state must be resent
+	transport.SetWriteError(nil)
+	transport.SetOpenError(nil)
+	assert.Eventually(t, func() bool {
+		report := transport.LastReport()
+		return BRAVO_CONNECTED.Load() && report != nil && report[1] == 0x02
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestUpdateLedsCoalescesRapidChanges(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport, WithMaxReportRate(5))
+	defer svc.Exit()
+
+	var state LEDState
+	for led := LED(0); int(led) < ledCount; led++ {
+		state.Set(led, true)
+		svc.Panel().Apply(state)
+	}
+
+	assert.Eventually(t, func() bool {
+		report := transport.LastReport()
+		return report != nil && bytes.Equal(report[1:5], []byte{0xff, 0xff, 0xff, 0x0f})
+	}, 2*time.Second, 10*time.Millisecond)
+	// the initial report plus at most one more per 200ms
+	assert.LessOrEqual(t, len(transport.Reports()), 3)
+}
+
+func TestUpdateLedsIdlesWithoutChanges(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	assert.Eventually(t, func() bool {
+		return len(transport.Reports()) == 1
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, transport.Reports(), 1)
+}
+
+func TestBravoDeliversInputEvents(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	transport.PushInputReport(inputReport(nil))
+	transport.PushInputReport(inputReport(nil, BUTTON_HDG))
+
+	var buttons []InputEvent
+	assert.Eventually(t, func() bool {
+		for {
+			select {
+			case event := <-svc.Input():
+				if event.Kind != AxisMoved {
+					buttons = append(buttons, event)
+				}
+			default:
+				return len(buttons) == 1
+			}
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, InputEvent{Kind: ButtonPressed, Button: BUTTON_HDG}, buttons[0])
+}
+
+func TestUpdateLedsAnimatesBlinkingLeds(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	svc.Panel().SetPattern(LED_MASTER_WARNING, LEDPattern{BlinkHz: 10})
+	var state LEDState
+	state.Set(LED_MASTER_WARNING, true)
+	svc.Panel().Apply(state)
+
+	// the LED toggles without any further Apply
+	assert.Eventually(t, func() bool {
+		var on, off bool
+		for _, report := range transport.Reports() {
+			if report[2]&0x40 != 0 {
+				on = true
+			} else if on {
+				off = true
+			}
+		}
+		return on && off
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWriteFailureDoesNotCloseDeviceDuringRead(t *testing.T) {
+	transport := NewFakeTransport()
+	svc := NewBravoServiceWithTransport(NewConsoleLogger(), transport)
+	defer svc.Exit()
+
+	// the reader is almost always waiting in a read when the write fails
+	time.Sleep(50 * time.Millisecond)
+	transport.SetWriteError(errors.New("device unplugged"))
+	var state LEDState
+	state.Set(LED_NAV, true)
+	svc.Panel().Apply(state)
+	assert.Eventually(t, func() bool {
+		return !BRAVO_CONNECTED.Load()
+	}, time.Second, 10*time.Millisecond)
+	// give the pending read time to return and close the handle
+	time.Sleep(2 * inputReadTimeout)
+
+	assert.Equal(t, 0, transport.ClosedWhileReading())
+}
@@ -0,0 +1,203 @@
+package honeycomb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+const (
+	reconnectMinBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff = 10 * time.Second
+)
+
+var errNotConnected = errors.New("bravo is not connected")
+
+// connection keeps one Device open for the lifetime of the service. A failed
+// write closes the handle and marks the Bravo as disconnected; run then
+// reopens it with exponential backoff until the device is plugged in again.
+//
+// Only one goroutine reads at a time. A handle that is dropped while that
+// read is waiting is closed once the read returns, since hidapi frees it on
+// close.
+type connection struct {
+	logger    pkg.Logger
+	transport Transport
+	onConnect func()
+
+	mu             sync.Mutex
+	device         Device
+	reading        Device
+	closeAfterRead bool
+	backoff        time.Duration
+	lost           chan struct{}
+}
+
+func newConnection(logger pkg.Logger, transport Transport, onConnect func()) *connection {
+	return &connection{
+		logger:    logger,
+		transport: transport,
+		onConnect: onConnect,
+		backoff:   reconnectMinBackoff,
+		lost:      make(chan struct{}, 1),
+	}
+}
+
+// connect opens the device if it is not open yet and reports whether it is
+// open afterwards.
+func (c *connection) connect() bool {
+	c.mu.Lock()
+	if c.device != nil {
+		c.mu.Unlock()
+		return true
+	}
+
+	device, err := c.transport.Open()
+	if err != nil || device == nil {
+		c.mu.Unlock()
+		c.logger.Debugf("failed to open device: %v", err)
+		return false
+	}
+	c.device = device
+	c.mu.Unlock()
+
+	c.logger.Info("Bravo connected")
+	BRAVO_CONNECTED.Store(true)
+	if c.onConnect != nil {
+		c.onConnect()
+	}
+	return true
+}
+
+// SendFeatureReport writes report to the open device. A write error is
+// treated as a disconnect.
+func (c *connection) SendFeatureReport(report []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.device == nil {
+		return errNotConnected
+	}
+
+	n, err := c.device.SendFeatureReport(report)
+	if err != nil {
+		c.disconnectLocked(err)
+		return err
+	}
+	if n != len(report) {
+		return fmt.Errorf("short write: %d of %d bytes", n, len(report))
+	}
+	c.backoff = reconnectMinBackoff
+	return nil
+}
+
+// ReadInputReport waits up to timeout for the next input report. The lock is
+// not held while waiting, so LED writes are not delayed by a pending read.
+// A read error is treated as a disconnect.
+func (c *connection) ReadInputReport(report []byte, timeout time.Duration) (int, error) {
+	c.mu.Lock()
+	device := c.device
+	c.reading = device
+	c.mu.Unlock()
+
+	if device == nil {
+		return 0, errNotConnected
+	}
+
+	n, err := device.ReadWithTimeout(report, timeout)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reading = nil
+	if c.closeAfterRead {
+		// the writer dropped the handle while we were waiting on it
+		c.closeAfterRead = false
+		c.closeDevice(device)
+	}
+	if err != nil {
+		if c.device == device {
+			c.disconnectLocked(err)
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+// closeLocked closes device, or leaves it to ReadInputReport if a read is
+// still waiting on it.
+func (c *connection) closeLocked(device Device) {
+	if device == c.reading {
+		c.closeAfterRead = true
+		return
+	}
+	c.closeDevice(device)
+}
+
+func (c *connection) closeDevice(device Device) {
+	if err := device.Close(); err != nil {
+		c.logger.Errorf("failed to close device: %v", err)
+	}
+}
+
+func (c *connection) disconnectLocked(reason error) {
+	c.logger.Errorf("Bravo disconnected: %v", reason)
+	c.closeLocked(c.device)
+	c.device = nil
+	BRAVO_CONNECTED.Store(false)
+
+	select {
+	case c.lost <- struct{}{}:
+	default:
+	}
+}
+
+func (c *connection) isConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.device != nil
+}
+
+// Close releases the device handle, if any.
+func (c *connection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.device == nil {
+		return
+	}
+	c.closeLocked(c.device)
+	c.device = nil
+}
+
+// run reconnects whenever the device goes away until ctx is canceled.
+func (c *connection) run(ctx context.Context) {
+	for {
+		if c.isConnected() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.lost:
+			}
+		}
+
+		c.mu.Lock()
+		wait := c.backoff
+		c.backoff *= 2
+		if c.backoff > reconnectMaxBackoff {
+			c.backoff = reconnectMaxBackoff
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		c.connect()
+	}
+}
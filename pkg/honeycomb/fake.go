@@ -0,0 +1,169 @@
+package honeycomb
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errFakeDeviceClosed = errors.New("fake device is closed")
+
+// FakeTransport is an in-memory Transport that records every feature report
+// written to the devices it opens. It lets tests assert the exact reports a
+// profile produces without a physical Bravo attached. Input reports queued
+// with PushInputReport are handed out by the open device in order.
+type FakeTransport struct {
+	mu       sync.Mutex
+	reports  [][]byte
+	opens    int
+	closes   int
+	openErr  error
+	writeErr error
+	inputs   chan []byte
+
+	closedWhileReading int
+}
+
+func NewFakeTransport() *FakeTransport {
+	return &FakeTransport{
+		inputs: make(chan []byte, 64),
+	}
+}
+
+func (f *FakeTransport) Open() (Device, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.openErr != nil {
+		return nil, f.openErr
+	}
+	f.opens++
+	return &fakeDevice{transport: f}, nil
+}
+
+func (f *FakeTransport) Exit() error {
+	return nil
+}
+
+// SetOpenError makes every following Open call fail with err. Pass nil to
+// plug the device back in.
+func (f *FakeTransport) SetOpenError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.openErr = err
+}
+
+// SetWriteError makes every following feature report write fail with err.
+func (f *FakeTransport) SetWriteError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writeErr = err
+}
+
+// PushInputReport queues an input report as if the Bravo had sent it.
+func (f *FakeTransport) PushInputReport(report []byte) {
+	f.inputs <- append([]byte(nil), report...)
+}
+
+// Opens returns how many times a device has been opened.
+func (f *FakeTransport) Opens() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opens
+}
+
+// OpenDevices returns how many opened devices have not been closed yet.
+func (f *FakeTransport) OpenDevices() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opens - f.closes
+}
+
+// ClosedWhileReading returns how many devices were closed while a read was
+// still waiting on them. A real hidapi handle is freed on close, so this must
+// stay 0.
+func (f *FakeTransport) ClosedWhileReading() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closedWhileReading
+}
+
+// Reports returns a copy of every feature report written so far, oldest first.
+func (f *FakeTransport) Reports() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := make([][]byte, len(f.reports))
+	for i, report := range f.reports {
+		res[i] = append([]byte(nil), report...)
+	}
+	return res
+}
+
+// LastReport returns a copy of the most recent feature report, or nil.
+func (f *FakeTransport) LastReport() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.reports) == 0 {
+		return nil
+	}
+	return append([]byte(nil), f.reports[len(f.reports)-1]...)
+}
+
+type fakeDevice struct {
+	transport *FakeTransport
+	closed    bool
+	reading   bool
+}
+
+func (d *fakeDevice) SendFeatureReport(p []byte) (int, error) {
+	f := d.transport
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if d.closed {
+		return 0, errFakeDeviceClosed
+	}
+	if f.writeErr != nil {
+		return 0, f.writeErr
+	}
+	f.reports = append(f.reports, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (d *fakeDevice) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	f := d.transport
+	f.mu.Lock()
+	closed := d.closed
+	d.reading = !closed
+	f.mu.Unlock()
+	if closed {
+		return 0, errFakeDeviceClosed
+	}
+	defer func() {
+		f.mu.Lock()
+		d.reading = false
+		f.mu.Unlock()
+	}()
+
+	select {
+	case report := <-f.inputs:
+		return copy(p, report), nil
+	case <-time.After(timeout):
+		return 0, nil
+	}
+}
+
+func (d *fakeDevice) Close() error {
+	d.transport.mu.Lock()
+	defer d.transport.mu.Unlock()
+	if d.reading {
+		d.transport.closedWhileReading++
+	}
+	if !d.closed {
+		d.transport.closes++
+	}
+	d.closed = true
+	return nil
+}
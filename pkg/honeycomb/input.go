@@ -0,0 +1,237 @@
+package honeycomb
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Button identifies one of the 48 buttons in the Bravo input report. The
+// value is the bit position in the button field, which is also the
+// ButtonNumber the Honeycomb configurator uses (see
+// configurator/Default_Throttle.json). Toggle switches, the gear lever, the
+// flap lever and the rotary encoder all show up as buttons.
+type Button uint8
+
+const (
+	// autopilot buttons
+	BUTTON_HDG Button = 0
+	BUTTON_NAV Button = 1
+	BUTTON_APR Button = 2
+	BUTTON_REV Button = 3
+	BUTTON_ALT Button = 4
+	BUTTON_VS  Button = 5
+	BUTTON_IAS Button = 6
+	BUTTON_AP  Button = 7
+
+	// rotary encoder
+	BUTTON_ENCODER_INC Button = 12
+	BUTTON_ENCODER_DEC Button = 13
+
+	// flap lever
+	BUTTON_FLAPS_DOWN Button = 14
+	BUTTON_FLAPS_UP   Button = 15
+
+	// mode selector
+	BUTTON_SELECTOR_IAS Button = 16
+	BUTTON_SELECTOR_CRS Button = 17
+	BUTTON_SELECTOR_HDG Button = 18
+	BUTTON_SELECTOR_VS  Button = 19
+	BUTTON_SELECTOR_ALT Button = 20
+
+	// trim wheel
+	BUTTON_TRIM_DOWN Button = 21
+	BUTTON_TRIM_UP   Button = 22
+
+	// reverse detents below idle, lever 1 to 6
+	BUTTON_LEVER_1_DETENT Button = 23
+	BUTTON_LEVER_2_DETENT Button = 24
+	BUTTON_LEVER_3_DETENT Button = 25
+	BUTTON_LEVER_4_DETENT Button = 26
+	BUTTON_LEVER_5_DETENT Button = 27
+	BUTTON_LEVER_6_DETENT Button = 28
+
+	// gear lever
+	BUTTON_GEAR_UP   Button = 30
+	BUTTON_GEAR_DOWN Button = 31
+
+	// toggle switches, switch 1 is the leftmost one
+	BUTTON_SWITCH_1_UP   Button = 32
+	BUTTON_SWITCH_1_DOWN Button = 33
+	BUTTON_SWITCH_2_UP   Button = 34
+	BUTTON_SWITCH_2_DOWN Button = 35
+	BUTTON_SWITCH_3_UP   Button = 36
+	BUTTON_SWITCH_3_DOWN Button = 37
+	BUTTON_SWITCH_4_UP   Button = 38
+	BUTTON_SWITCH_4_DOWN Button = 39
+	BUTTON_SWITCH_5_UP   Button = 40
+	BUTTON_SWITCH_5_DOWN Button = 41
+	BUTTON_SWITCH_6_UP   Button = 42
+	BUTTON_SWITCH_6_DOWN Button = 43
+	BUTTON_SWITCH_7_UP   Button = 44
+	BUTTON_SWITCH_7_DOWN Button = 45
+
+	buttonCount = 48
+)
+
+var buttonNames = map[Button]string{
+	BUTTON_HDG:            "HDG",
+	BUTTON_NAV:            "NAV",
+	BUTTON_APR:            "APR",
+	BUTTON_REV:            "REV",
+	BUTTON_ALT:            "ALT",
+	BUTTON_VS:             "VS",
+	BUTTON_IAS:            "IAS",
+	BUTTON_AP:             "AP",
+	BUTTON_ENCODER_INC:    "Encoder Increase",
+	BUTTON_ENCODER_DEC:    "Encoder Decrease",
+	BUTTON_FLAPS_DOWN:     "Flaps Down",
+	BUTTON_FLAPS_UP:       "Flaps Up",
+	BUTTON_SELECTOR_IAS:   "Selector IAS",
+	BUTTON_SELECTOR_CRS:   "Selector CRS",
+	BUTTON_SELECTOR_HDG:   "Selector HDG",
+	BUTTON_SELECTOR_VS:    "Selector VS",
+	BUTTON_SELECTOR_ALT:   "Selector ALT",
+	BUTTON_TRIM_DOWN:      "Trim Down",
+	BUTTON_TRIM_UP:        "Trim Up",
+	BUTTON_LEVER_1_DETENT: "Lever 1 Detent",
+	BUTTON_LEVER_2_DETENT: "Lever 2 Detent",
+	BUTTON_LEVER_3_DETENT: "Lever 3 Detent",
+	BUTTON_LEVER_4_DETENT: "Lever 4 Detent",
+	BUTTON_LEVER_5_DETENT: "Lever 5 Detent",
+	BUTTON_LEVER_6_DETENT: "Lever 6 Detent",
+	BUTTON_GEAR_UP:        "Gear Up",
+	BUTTON_GEAR_DOWN:      "Gear Down",
+	BUTTON_SWITCH_1_UP:    "Switch 1 Up",
+	BUTTON_SWITCH_1_DOWN:  "Switch 1 Down",
+	BUTTON_SWITCH_2_UP:    "Switch 2 Up",
+	BUTTON_SWITCH_2_DOWN:  "Switch 2 Down",
+	BUTTON_SWITCH_3_UP:    "Switch 3 Up",
+	BUTTON_SWITCH_3_DOWN:  "Switch 3 Down",
+	BUTTON_SWITCH_4_UP:    "Switch 4 Up",
+	BUTTON_SWITCH_4_DOWN:  "Switch 4 Down",
+	BUTTON_SWITCH_5_UP:    "Switch 5 Up",
+	BUTTON_SWITCH_5_DOWN:  "Switch 5 Down",
+	BUTTON_SWITCH_6_UP:    "Switch 6 Up",
+	BUTTON_SWITCH_6_DOWN:  "Switch 6 Down",
+	BUTTON_SWITCH_7_UP:    "Switch 7 Up",
+	BUTTON_SWITCH_7_DOWN:  "Switch 7 Down",
+}
+
+func (b Button) String() string {
+	if name, ok := buttonNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("Button %d", b+1)
+}
+
+// Axis identifies one of the analog axes in the Bravo input report, in
+// report order. The levers are numbered from left to right.
+type Axis uint8
+
+const (
+	AXIS_LEVER_1 Axis = iota
+	AXIS_LEVER_2
+	AXIS_LEVER_3
+	AXIS_LEVER_4
+	AXIS_LEVER_5
+	AXIS_LEVER_6
+	AXIS_LEVER_7
+
+	axisCount = iota
+)
+
+func (a Axis) String() string {
+	return fmt.Sprintf("Lever %d", a+1)
+}
+
+// Bravo input report layout: every axis is a little endian uint16, followed by
+// one bit per button.
+const (
+	inputAxesOffset    = 0
+	inputButtonsOffset = inputAxesOffset + axisCount*2
+	inputReportLength  = inputButtonsOffset + buttonCount/8
+)
+
+type InputEventKind uint8
+
+const (
+	ButtonPressed InputEventKind = iota
+	ButtonReleased
+	AxisMoved
+)
+
+// InputEvent is a single change decoded from a Bravo input report. Button is
+// set for ButtonPressed and ButtonReleased, Axis and Value for AxisMoved.
+// Value is the lever position scaled to 0..1.
+type InputEvent struct {
+	Kind   InputEventKind
+	Button Button
+	Axis   Axis
+	Value  float32
+}
+
+func (e InputEvent) String() string {
+	switch e.Kind {
+	case ButtonPressed:
+		return fmt.Sprintf("%s pressed", e.Button)
+	case ButtonReleased:
+		return fmt.Sprintf("%s released", e.Button)
+	default:
+		return fmt.Sprintf("%s moved to %.3f", e.Axis, e.Value)
+	}
+}
+
+// inputDecoder turns raw input reports into events by comparing each report
+// with the previous one.
+type inputDecoder struct {
+	buttons   uint64
+	axes      [axisCount]uint16
+	axesKnown bool
+}
+
+// reset forgets the previous report, e.g. after the device was reconnected.
+// All buttons are treated as released and every axis is reported again.
+func (d *inputDecoder) reset() {
+	*d = inputDecoder{}
+}
+
+// decode appends the events for report to events and returns the result.
+func (d *inputDecoder) decode(report []byte, events []InputEvent) ([]InputEvent, error) {
+	if len(report) < inputReportLength {
+		return events, fmt.Errorf("short input report: %d of %d bytes", len(report), inputReportLength)
+	}
+
+	for axis := Axis(0); axis < axisCount; axis++ {
+		offset := inputAxesOffset + int(axis)*2
+		raw := binary.LittleEndian.Uint16(report[offset : offset+2])
+		if d.axesKnown && raw == d.axes[axis] {
+			continue
+		}
+		d.axes[axis] = raw
+		events = append(events, InputEvent{
+			Kind:  AxisMoved,
+			Axis:  axis,
+			Value: float32(raw) / 0xFFFF,
+		})
+	}
+	d.axesKnown = true
+
+	var buttons uint64
+	for i := 0; i < buttonCount/8; i++ {
+		buttons |= uint64(report[inputButtonsOffset+i]) << (8 * i)
+	}
+	changed := buttons ^ d.buttons
+	for button := Button(0); button < buttonCount; button++ {
+		if changed&(1<<button) == 0 {
+			continue
+		}
+		kind := ButtonReleased
+		if buttons&(1<<button) != 0 {
+			kind = ButtonPressed
+		}
+		events = append(events, InputEvent{Kind: kind, Button: button})
+	}
+	d.buttons = buttons
+
+	return events, nil
+}
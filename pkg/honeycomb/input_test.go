@@ -0,0 +1,132 @@
+package honeycomb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func inputReport(axes map[Axis]uint16, buttons ...Button) []byte {
+	report := make([]byte, inputReportLength)
+	for axis, raw := range axes {
+		binary.LittleEndian.PutUint16(report[inputAxesOffset+int(axis)*2:], raw)
+	}
+	for _, button := range buttons {
+		report[inputButtonsOffset+int(button)/8] |= 1 << (button % 8)
+	}
+	return report
+}
+
+func TestInputDecoderReportsAllAxesOnFirstReport(t *testing.T) {
+	var decoder inputDecoder
+
+	events, err := decoder.decode(inputReport(map[Axis]uint16{AXIS_LEVER_2: 0xFFFF}), nil)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, axisCount)
+	assert.Equal(t, InputEvent{Kind: AxisMoved, Axis: AXIS_LEVER_2, Value: 1}, events[AXIS_LEVER_2])
+	assert.Equal(t, InputEvent{Kind: AxisMoved, Axis: AXIS_LEVER_1, Value: 0}, events[AXIS_LEVER_1])
+}
+
+func TestInputDecoderReportsButtonChanges(t *testing.T) {
+	var decoder inputDecoder
+	_, err := decoder.decode(inputReport(nil, BUTTON_SWITCH_1_DOWN), nil)
+	assert.NoError(t, err)
+
+	events, err := decoder.decode(inputReport(nil, BUTTON_SWITCH_1_UP, BUTTON_GEAR_DOWN), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []InputEvent{
+		{Kind: ButtonPressed, Button: BUTTON_GEAR_DOWN},
+		{Kind: ButtonPressed, Button: BUTTON_SWITCH_1_UP},
+		{Kind: ButtonReleased, Button: BUTTON_SWITCH_1_DOWN},
+	}, events)
+}
+
+func TestInputDecoderSkipsUnchangedAxes(t *testing.T) {
+	var decoder inputDecoder
+	_, err := decoder.decode(inputReport(map[Axis]uint16{AXIS_LEVER_1: 100}), nil)
+	assert.NoError(t, err)
+
+	events, err := decoder.decode(inputReport(map[Axis]uint16{AXIS_LEVER_1: 100}, BUTTON_AP), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []InputEvent{{Kind: ButtonPressed, Button: BUTTON_AP}}, events)
+}
+
+func TestInputDecoderRejectsShortReport(t *testing.T) {
+	var decoder inputDecoder
+
+	_, err := decoder.decode(make([]byte, inputReportLength-1), nil)
+
+	assert.Error(t, err)
+}
+
+func TestInputDecoderDecodesRawSelectorReport(t *testing.T) {
+	var decoder inputDecoder
+	// every lever at 0, mode selector on ALT (button 20)
+	report := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x10, 0x00, 0x00, 0x00,
+	}
+
+	events, err := decoder.decode(report, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, InputEvent{Kind: ButtonPressed, Button: BUTTON_SELECTOR_ALT}, events[axisCount])
+}
+
+// The buttons of the configurator's default profile are numbered by their bit
+// in the input report, so a report with that bit set has to decode to the
+// button the profile binds it to.
+func TestInputDecoderMatchesConfiguratorButtonNumbers(t *testing.T) {
+	raw, err := os.ReadFile("../../configurator/Default_Throttle.json")
+	assert.NoError(t, err)
+	var profile struct {
+		Data []struct {
+			ButtonNumber int
+			PressEvent   []struct {
+				Name      string
+				Variables []struct {
+					Variable string
+					Value    string
+				}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(raw, &profile))
+
+	want := map[int]string{}
+	for _, btn := range profile.Data {
+		for _, event := range btn.PressEvent {
+			switch event.Name {
+			case "AP_INCREASE":
+				want[btn.ButtonNumber] = "Encoder Increase"
+			case "AP_DECREASE":
+				want[btn.ButtonNumber] = "Encoder Decrease"
+			}
+			for _, variable := range event.Variables {
+				if variable.Variable == "INT:FCU_SELECTOR, string" {
+					want[btn.ButtonNumber] = "Selector " + variable.Value
+				}
+			}
+		}
+	}
+	assert.Len(t, want, 7)
+
+	for number, name := range want {
+		var decoder inputDecoder
+		report := make([]byte, inputReportLength)
+		report[inputButtonsOffset+number/8] = 1 << (number % 8)
+
+		events, err := decoder.decode(report, nil)
+
+		assert.NoError(t, err)
+		if assert.Len(t, events, axisCount+1, "button %d", number) {
+			assert.Equal(t, name, events[axisCount].Button.String(), "button %d", number)
+		}
+	}
+}
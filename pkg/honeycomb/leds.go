@@ -3,528 +3,274 @@ package honeycomb
 import (
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-const (
-	LED_FUEL_PUMP     = 1
-	LED_PARKING_BRAKE = 2
-	LED_LOW_VOLTS     = 4
-	LED_DOOR          = 8
-
-	LED_LOW_OIL_PRESS  = 1
-	LED_LOW_FUEL_PRESS = 2
-	LED_ANTI_ICE       = 4
-	LED_STARTER        = 8
-	LED_APU            = 16
-	LED_MASTER_CAUTION = 32
-	LED_VACUUM         = 64
-	LED_LOW_HYD_PRESS  = 128
-
-	LED_LEFT_GEAR_GREEN  = 1
-	LED_LEFT_GEAR_RED    = 2
-	LED_NOSE_GEAR_GREEN  = 4
-	LED_NOSE_GEAR_RED    = 8
-	LED_RIGHT_GEAR_GREEN = 16
-	LED_RIGHT_GEAR_RED   = 32
-	LED_MASTER_WARNING   = 64
-	LED_ENGINE_FIRE      = 128
-
-	LED_HEADING = 1
-	LED_NAV     = 2
-	LED_APR     = 4
-	LED_REV     = 8
-	LED_ALT     = 16
-	LED_VS      = 32
-	LED_IAS     = 64
-	LED_AP      = 128
-)
+// LED identifies a single light on the Bravo. The value is the bit position
+// of the LED in LEDState; every 8 LEDs make up one byte of the HID feature
+// report, in the order AUTO_PILOT_W, LANDING_GEAR_W, ANUNCIATOR_W1,
+// ANUNCIATOR_W2.
+type LED uint8
 
-// Global variables for state
-var (
-	ANUNCIATOR_W2          byte = 0
-	ANUNCIATOR_W1          byte = 0
-	LANDING_GEAR_W         byte = 0
-	AUTO_PILOT_W           byte = 0
-	LED_STATE_CHANGED           = false
-	LED_STATE_CHANGED_LOCK      = &sync.Mutex{}
+const (
+	// AUTO_PILOT_W LEDs
+	LED_HEADING LED = iota
+	LED_NAV
+	LED_APR
+	LED_REV
+	LED_ALT
+	LED_VS
+	LED_IAS
+	LED_AP
+
+	// LANDING_GEAR_W LEDs
+	LED_LEFT_GEAR_GREEN
+	LED_LEFT_GEAR_RED
+	LED_NOSE_GEAR_GREEN
+	LED_NOSE_GEAR_RED
+	LED_RIGHT_GEAR_GREEN
+	LED_RIGHT_GEAR_RED
+	LED_MASTER_WARNING
+	LED_ENGINE_FIRE
+
+	// ANUNCIATOR_W1 LEDs
+	LED_LOW_OIL_PRESS
+	LED_LOW_FUEL_PRESS
+	LED_ANTI_ICE
+	LED_STARTER
+	LED_APU
+	LED_MASTER_CAUTION
+	LED_VACUUM
+	LED_LOW_HYD_PRESS
+
+	// ANUNCIATOR_W2 LEDs
+	LED_FUEL_PUMP
+	LED_PARKING_BRAKE
+	LED_LOW_VOLTS
+	LED_DOOR
+
+	ledCount = iota
 )
 
-func setBit(val byte, bit byte) byte {
-	return val | bit
-}
-
-func clearBit(val byte, bit byte) byte {
-	return val &^ bit
-}
-
-func UpdateLEDStateChanged(val bool) {
-	LED_STATE_CHANGED_LOCK.Lock()
-	defer LED_STATE_CHANGED_LOCK.Unlock()
-	LED_STATE_CHANGED = LED_STATE_CHANGED || val
-}
-
-func OnLEDFuelPump() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = setBit(ANUNCIATOR_W2, LED_FUEL_PUMP)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OffLEDFuelPump() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = clearBit(ANUNCIATOR_W2, LED_FUEL_PUMP)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OnLEDParkingBrake() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = setBit(ANUNCIATOR_W2, LED_PARKING_BRAKE)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OffLEDParkingBrake() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = clearBit(ANUNCIATOR_W2, LED_PARKING_BRAKE)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OnLEDLowVolts() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = setBit(ANUNCIATOR_W2, LED_LOW_VOLTS)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OffLEDLowVolts() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = clearBit(ANUNCIATOR_W2, LED_LOW_VOLTS)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OnLEDDoor() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = setBit(ANUNCIATOR_W2, LED_DOOR)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-func OffLEDDoor() {
-	ANUNCIATOR_W2_BEFORE := ANUNCIATOR_W2
-	ANUNCIATOR_W2 = clearBit(ANUNCIATOR_W2, LED_DOOR)
-	UpdateLEDStateChanged(ANUNCIATOR_W2_BEFORE != ANUNCIATOR_W2)
-}
-
-// ANUNCIATOR_W1 LEDs
-func OnLEDLowOilPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_LOW_OIL_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-func OffLEDLowOilPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_LOW_OIL_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDLowFuelPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_LOW_FUEL_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDLowFuelPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_LOW_FUEL_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDAntiIce() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_ANTI_ICE)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDAntiIce() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_ANTI_ICE)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDStarter() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_STARTER)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDStarter() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_STARTER)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDApu() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_APU)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDApu() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_APU)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDMasterCaution() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_MASTER_CAUTION)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDMasterCaution() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_MASTER_CAUTION)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDVacuum() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_VACUUM)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDVacuum() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_VACUUM)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OnLEDLowHydPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = setBit(ANUNCIATOR_W1, LED_LOW_HYD_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-func OffLEDLowHydPress() {
-	ANUNCIATOR_W1_BEFORE := ANUNCIATOR_W1
-	ANUNCIATOR_W1 = clearBit(ANUNCIATOR_W1, LED_LOW_HYD_PRESS)
-	UpdateLEDStateChanged(ANUNCIATOR_W1_BEFORE != ANUNCIATOR_W1)
-}
-
-// LANDING_GEAR_W LEDs
-func OnLEDLeftGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_LEFT_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDLeftGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_LEFT_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDLeftGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_LEFT_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDLeftGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_LEFT_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDNoseGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_NOSE_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDNoseGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_NOSE_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDNoseGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_NOSE_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDNoseGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_NOSE_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDRightGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_RIGHT_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDRightGearGreen() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_RIGHT_GEAR_GREEN)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDRightGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_RIGHT_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDRightGearRed() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_RIGHT_GEAR_RED)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLedGearGreen() {
-	OnLEDLeftGearGreen()
-	OffLEDLeftGearRed()
-	OnLEDNoseGearGreen()
-	OffLEDNoseGearRed()
-	OnLEDRightGearGreen()
-	OffLEDRightGearRed()
-}
-
-func OnLedGearRed() {
-	OnLEDLeftGearRed()
-	OffLEDLeftGearGreen()
-	OnLEDNoseGearRed()
-	OffLEDNoseGearGreen()
-	OnLEDRightGearRed()
-	OffLEDRightGearGreen()
-}
-
-func OnLEDMasterWarning() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_MASTER_WARNING)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDMasterWarning() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_MASTER_WARNING)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OnLEDEngineFire() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = setBit(LANDING_GEAR_W, LED_ENGINE_FIRE)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-func OffLEDEngineFire() {
-	LANDING_GEAR_W_BEFORE := LANDING_GEAR_W
-	LANDING_GEAR_W = clearBit(LANDING_GEAR_W, LED_ENGINE_FIRE)
-	UpdateLEDStateChanged(LANDING_GEAR_W_BEFORE != LANDING_GEAR_W)
-}
-
-// AUTO_PILOT_W LEDs
-func OnLEDHeading() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_HEADING)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
-
-func OffLEDHeading() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_HEADING)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+var ledNames = [ledCount]string{
+	LED_HEADING:          "Heading",
+	LED_NAV:              "Navigation",
+	LED_APR:              "Approach",
+	LED_REV:              "Reverse",
+	LED_ALT:              "Altitude",
+	LED_VS:               "Vertical Speed",
+	LED_IAS:              "Indicated Airspeed",
+	LED_AP:               "Autopilot",
+	LED_LEFT_GEAR_GREEN:  "Left Gear Green",
+	LED_LEFT_GEAR_RED:    "Left Gear Red",
+	LED_NOSE_GEAR_GREEN:  "Nose Gear Green",
+	LED_NOSE_GEAR_RED:    "Nose Gear Red",
+	LED_RIGHT_GEAR_GREEN: "Right Gear Green",
+	LED_RIGHT_GEAR_RED:   "Right Gear Red",
+	LED_MASTER_WARNING:   "Master Warning",
+	LED_ENGINE_FIRE:      "Engine Fire",
+	LED_LOW_OIL_PRESS:    "Low Oil Pressure",
+	LED_LOW_FUEL_PRESS:   "Low Fuel Pressure",
+	LED_ANTI_ICE:         "Anti-Ice",
+	LED_STARTER:          "Starter",
+	LED_APU:              "APU",
+	LED_MASTER_CAUTION:   "Master Caution",
+	LED_VACUUM:           "Vacuum",
+	LED_LOW_HYD_PRESS:    "Low Hydraulic Pressure",
+	LED_FUEL_PUMP:        "Fuel Pump",
+	LED_PARKING_BRAKE:    "Parking Brake",
+	LED_LOW_VOLTS:        "Low Volts",
+	LED_DOOR:             "Door",
+}
+
+var ledWordNames = [4]string{"AUTO_PILOT_W", "LANDING_GEAR_W", "ANUNCIATOR_W1", "ANUNCIATOR_W2"}
+
+func (l LED) String() string {
+	if int(l) >= ledCount {
+		return "Unknown"
+	}
+	return ledNames[l]
 }
 
-func OnLEDNav() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_NAV)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
+// LEDState is a snapshot of every LED on the panel. The zero value is all off.
+type LEDState uint32
 
-func OffLEDNav() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_NAV)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+func (s LEDState) IsOn(led LED) bool {
+	return s&(1<<led) != 0
 }
 
-func OnLEDAPR() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_APR)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
-
-func OffLEDAPR() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_APR)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+func (s *LEDState) Set(led LED, on bool) {
+	if on {
+		*s |= 1 << led
+	} else {
+		*s &^= 1 << led
+	}
 }
 
-func OnLEDREV() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_REV)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+// Report fills the first five bytes of a Bravo LED feature report.
+func (s LEDState) Report(buf []byte) {
+	buf[0] = 0x0
+	buf[1] = byte(s)
+	buf[2] = byte(s >> 8)
+	buf[3] = byte(s >> 16)
+	buf[4] = byte(s >> 24)
 }
 
-func OffLEDREV() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_REV)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+func (s LEDState) String() string {
+	var sb strings.Builder
+	for led := LED(0); int(led) < ledCount; led++ {
+		if led%8 == 0 {
+			sb.WriteString("\n")
+			sb.WriteString(ledWordNames[led/8])
+			sb.WriteString(" LEDs:\n")
+		}
+		if s.IsOn(led) {
+			sb.WriteString("- ")
+			sb.WriteString(led.String())
+			sb.WriteString(" is ON\n")
+		}
+	}
+	return sb.String()
 }
 
-func OnLEDAlt() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_ALT)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+// LEDPattern is how an LED that is on gets shown. The zero value is steady.
+// With BlinkHz set the LED blinks at that rate; with Flash set as well it only
+// blinks for that long after turning on and then stays steady.
+type LEDPattern struct {
+	BlinkHz float64
+	Flash   time.Duration
 }
 
-func OffLEDAlt() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_ALT)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
+// PanelState holds the LEDs shown on one Bravo. The flight loop builds a
+// complete LEDState and hands it over with Apply; the LED writer is woken up
+// through changed and picks up the latest snapshot. Both sides can run on
+// different goroutines. Blinking is done by the writer through Render, so the
+// flight loop only ever says whether an LED is on.
+type PanelState struct {
+	state   atomic.Uint32
+	dirty   atomic.Bool
+	changed chan struct{}
 
-func OnLEDVS() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_VS)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+	mu       sync.Mutex
+	patterns [ledCount]LEDPattern
+	onSince  [ledCount]time.Time
 }
 
-func OffLEDVS() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_VS)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+func NewPanelState() *PanelState {
+	return &PanelState{
+		changed: make(chan struct{}, 1),
+	}
 }
 
-func OnLEDIAS() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_IAS)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+// Snapshot returns the current LED state.
+func (p *PanelState) Snapshot() LEDState {
+	return LEDState(p.state.Load())
 }
 
-func OffLEDIAS() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_IAS)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
+// Apply replaces the whole panel state and reports whether any LED changed.
+func (p *PanelState) Apply(state LEDState) bool {
+	// swap under mu so Render never sees an LED on before its start time
+	now := time.Now()
+	p.mu.Lock()
+	old := p.state.Swap(uint32(state))
+	if old == uint32(state) {
+		p.mu.Unlock()
+		return false
+	}
 
-func OnLEDAP() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = setBit(AUTO_PILOT_W, LED_AP)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
-}
+	// remember when LEDs came on so their pattern starts from there
+	turnedOn := state &^ LEDState(old)
+	for led := LED(0); int(led) < ledCount; led++ {
+		if turnedOn.IsOn(led) {
+			p.onSince[led] = now
+		}
+	}
+	p.mu.Unlock()
 
-func OffLEDAP() {
-	AUTO_PILOT_W_BEFORE := AUTO_PILOT_W
-	AUTO_PILOT_W = clearBit(AUTO_PILOT_W, LED_AP)
-	UpdateLEDStateChanged(AUTO_PILOT_W_BEFORE != AUTO_PILOT_W)
+	p.markDirty()
+	return true
 }
 
-// DebugPrintLEDStates prints the current state of all LEDs
-func (b *bravoService) DebugPrintLEDStates() {
-	var sb strings.Builder
-
-	sb.WriteString("\nANUNCIATOR_W2 LEDs:\n")
-	if ANUNCIATOR_W2&LED_FUEL_PUMP != 0 {
-		sb.WriteString("- Fuel Pump is ON\n")
-	}
-	if ANUNCIATOR_W2&LED_PARKING_BRAKE != 0 {
-		sb.WriteString("- Parking Brake is ON\n")
-	}
-	if ANUNCIATOR_W2&LED_LOW_VOLTS != 0 {
-		sb.WriteString("- Low Volts is ON\n")
-	}
-	if ANUNCIATOR_W2&LED_DOOR != 0 {
-		sb.WriteString("- Door is ON\n")
-	}
-
-	sb.WriteString("ANUNCIATOR_W1 LEDs:\n")
-	if ANUNCIATOR_W1&LED_LOW_OIL_PRESS != 0 {
-		sb.WriteString("- Low Oil Pressure is ON\n")
+// SetPattern changes how led is shown while it is on.
+func (p *PanelState) SetPattern(led LED, pattern LEDPattern) {
+	if int(led) >= ledCount {
+		return
 	}
-	if ANUNCIATOR_W1&LED_LOW_FUEL_PRESS != 0 {
-		sb.WriteString("- Low Fuel Pressure is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_ANTI_ICE != 0 {
-		sb.WriteString("- Anti-Ice is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_STARTER != 0 {
-		sb.WriteString("- Starter is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_APU != 0 {
-		sb.WriteString("- APU is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_MASTER_CAUTION != 0 {
-		sb.WriteString("- Master Caution is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_VACUUM != 0 {
-		sb.WriteString("- Vacuum is ON\n")
-	}
-	if ANUNCIATOR_W1&LED_LOW_HYD_PRESS != 0 {
-		sb.WriteString("- Low Hydraulic Pressure is ON\n")
+	p.mu.Lock()
+	p.patterns[led] = pattern
+	p.mu.Unlock()
+	p.markDirty()
+}
+
+// ResetPatterns makes every LED steady again.
+func (p *PanelState) ResetPatterns() {
+	p.mu.Lock()
+	p.patterns = [ledCount]LEDPattern{}
+	p.mu.Unlock()
+	p.markDirty()
+}
+
+// Render returns the LEDs that are lit at now, with blinking LEDs switched
+// off during the dark half of their cycle. The duration is how long until the
+// result changes because of a pattern, or 0 if nothing is animating.
+func (p *PanelState) Render(now time.Time) (LEDState, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.Snapshot()
+
+	var next time.Duration
+	for led := LED(0); int(led) < ledCount; led++ {
+		pattern := p.patterns[led]
+		if !state.IsOn(led) || pattern.BlinkHz <= 0 {
+			continue
+		}
+
+		elapsed := now.Sub(p.onSince[led])
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		if pattern.Flash > 0 && elapsed >= pattern.Flash {
+			continue
+		}
+
+		half := time.Duration(float64(time.Second) / pattern.BlinkHz / 2)
+		if half <= 0 {
+			continue
+		}
+		if (elapsed/half)%2 == 1 {
+			state.Set(led, false)
+		}
+
+		wait := half - elapsed%half
+		if pattern.Flash > 0 && pattern.Flash-elapsed < wait {
+			wait = pattern.Flash - elapsed
+		}
+		if next == 0 || wait < next {
+			next = wait
+		}
 	}
+	return state, next
+}
 
-	sb.WriteString("LANDING_GEAR_W LEDs:\n")
-	if LANDING_GEAR_W&LED_LEFT_GEAR_GREEN != 0 {
-		sb.WriteString("- Left Gear Green is ON\n")
-	}
-	if LANDING_GEAR_W&LED_LEFT_GEAR_RED != 0 {
-		sb.WriteString("- Left Gear Red is ON\n")
-	}
-	if LANDING_GEAR_W&LED_NOSE_GEAR_GREEN != 0 {
-		sb.WriteString("- Nose Gear Green is ON\n")
-	}
-	if LANDING_GEAR_W&LED_NOSE_GEAR_RED != 0 {
-		sb.WriteString("- Nose Gear Red is ON\n")
-	}
-	if LANDING_GEAR_W&LED_RIGHT_GEAR_GREEN != 0 {
-		sb.WriteString("- Right Gear Green is ON\n")
-	}
-	if LANDING_GEAR_W&LED_RIGHT_GEAR_RED != 0 {
-		sb.WriteString("- Right Gear Red is ON\n")
-	}
-	if LANDING_GEAR_W&LED_MASTER_WARNING != 0 {
-		sb.WriteString("- Master Warning is ON\n")
-	}
-	if LANDING_GEAR_W&LED_ENGINE_FIRE != 0 {
-		sb.WriteString("- Engine Fire is ON\n")
-	}
+// Invalidate forces the current state to be written again even if nothing
+// changed, e.g. after the device was reconnected.
+func (p *PanelState) Invalidate() {
+	p.markDirty()
+}
 
-	sb.WriteString("AUTO_PILOT_W LEDs:\n")
-	if AUTO_PILOT_W&LED_HEADING != 0 {
-		sb.WriteString("- Heading is ON\n")
-	}
-	if AUTO_PILOT_W&LED_NAV != 0 {
-		sb.WriteString("- Navigation is ON\n")
+func (p *PanelState) markDirty() {
+	p.dirty.Store(true)
+	select {
+	case p.changed <- struct{}{}:
+	default:
 	}
-	if AUTO_PILOT_W&LED_APR != 0 {
-		sb.WriteString("- Approach is ON\n")
-	}
-	if AUTO_PILOT_W&LED_REV != 0 {
-		sb.WriteString("- Reverse is ON\n")
-	}
-	if AUTO_PILOT_W&LED_ALT != 0 {
-		sb.WriteString("- Altitude is ON\n")
-	}
-	if AUTO_PILOT_W&LED_VS != 0 {
-		sb.WriteString("- Vertical Speed is ON\n")
-	}
-	if AUTO_PILOT_W&LED_IAS != 0 {
-		sb.WriteString("- Indicated Airspeed is ON\n")
-	}
-	if AUTO_PILOT_W&LED_AP != 0 {
-		sb.WriteString("- Autopilot is ON\n")
-	}
-
-	// Log the complete message at once
-	b.Logger.Debug(sb.String())
 }
 
-func AllOff() {
-	LED_STATE_CHANGED_LOCK.Lock()
-	defer LED_STATE_CHANGED_LOCK.Unlock()
-	ANUNCIATOR_W2 = 0
-	ANUNCIATOR_W1 = 0
-	LANDING_GEAR_W = 0
-	AUTO_PILOT_W = 0
-	LED_STATE_CHANGED = true
+// takeDirty returns the current state if it has not been written yet.
+func (p *PanelState) takeDirty() (LEDState, bool) {
+	if !p.dirty.Swap(false) {
+		return 0, false
+	}
+	return p.Snapshot(), true
 }
@@ -0,0 +1,147 @@
+package honeycomb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLEDStateReportLayout(t *testing.T) {
+	var state LEDState
+	state.Set(LED_IAS, true)
+	state.Set(LED_RIGHT_GEAR_RED, true)
+	state.Set(LED_ENGINE_FIRE, true)
+	state.Set(LED_LOW_HYD_PRESS, true)
+	state.Set(LED_PARKING_BRAKE, true)
+
+	report := make([]byte, 65)
+	state.Report(report)
+
+	assert.Equal(t, []byte{0x00, 0x40, 0xA0, 0x80, 0x02}, report[:5])
+}
+
+func TestLEDStateSetAndClear(t *testing.T) {
+	var state LEDState
+	state.Set(LED_APU, true)
+	assert.True(t, state.IsOn(LED_APU))
+	assert.False(t, state.IsOn(LED_VACUUM))
+
+	state.Set(LED_APU, false)
+	assert.Equal(t, LEDState(0), state)
+}
+
+func TestPanelStateApplyReportsChanges(t *testing.T) {
+	panel := NewPanelState()
+
+	var state LEDState
+	state.Set(LED_DOOR, true)
+	assert.True(t, panel.Apply(state))
+	assert.False(t, panel.Apply(state))
+	assert.Equal(t, state, panel.Snapshot())
+
+	written, dirty := panel.takeDirty()
+	assert.True(t, dirty)
+	assert.Equal(t, state, written)
+
+	_, dirty = panel.takeDirty()
+	assert.False(t, dirty)
+
+	panel.Invalidate()
+	_, dirty = panel.takeDirty()
+	assert.True(t, dirty)
+}
+
+func TestPanelStateNotifiesOnChange(t *testing.T) {
+	panel := NewPanelState()
+
+	var state LEDState
+	state.Set(LED_APU, true)
+	panel.Apply(state)
+	panel.Apply(0)
+
+	// notifications are coalesced into one pending signal
+	assert.Len(t, panel.changed, 1)
+	<-panel.changed
+	assert.False(t, panel.Apply(0))
+	assert.Len(t, panel.changed, 0)
+
+	panel.Invalidate()
+	assert.Len(t, panel.changed, 1)
+}
+
+func TestPanelStateRenderBlinksLeds(t *testing.T) {
+	panel := NewPanelState()
+	panel.SetPattern(LED_MASTER_CAUTION, LEDPattern{BlinkHz: 2})
+
+	var state LEDState
+	state.Set(LED_MASTER_CAUTION, true)
+	state.Set(LED_AP, true)
+	panel.Apply(state)
+	start := time.Now()
+
+	lit, next := panel.Render(start.Add(100 * time.Millisecond))
+	assert.Equal(t, state, lit)
+	assert.Greater(t, next, time.Duration(0))
+	assert.LessOrEqual(t, next, 150*time.Millisecond)
+
+	lit, _ = panel.Render(start.Add(300 * time.Millisecond))
+	assert.False(t, lit.IsOn(LED_MASTER_CAUTION))
+	assert.True(t, lit.IsOn(LED_AP))
+}
+
+func TestPanelStateRenderFlashesThenStaysOn(t *testing.T) {
+	panel := NewPanelState()
+	panel.SetPattern(LED_AP, LEDPattern{BlinkHz: 2, Flash: time.Second})
+
+	var state LEDState
+	state.Set(LED_AP, true)
+	panel.Apply(state)
+	start := time.Now()
+
+	lit, _ := panel.Render(start.Add(300 * time.Millisecond))
+	assert.False(t, lit.IsOn(LED_AP))
+
+	lit, next := panel.Render(start.Add(1100 * time.Millisecond))
+	assert.True(t, lit.IsOn(LED_AP))
+	assert.Equal(t, time.Duration(0), next)
+}
+
+func TestPanelStateRenderIgnoresPatternsOfLedsThatAreOff(t *testing.T) {
+	panel := NewPanelState()
+	panel.SetPattern(LED_DOOR, LEDPattern{BlinkHz: 1})
+
+	lit, next := panel.Render(time.Now())
+	assert.Equal(t, LEDState(0), lit)
+	assert.Equal(t, time.Duration(0), next)
+
+	panel.ResetPatterns()
+	var state LEDState
+	state.Set(LED_DOOR, true)
+	panel.Apply(state)
+	lit, next = panel.Render(time.Now().Add(700 * time.Millisecond))
+	assert.True(t, lit.IsOn(LED_DOOR))
+	assert.Equal(t, time.Duration(0), next)
+}
+
+func TestPanelStateRenderNeverSeesLedWithoutStartTime(t *testing.T) {
+	var state LEDState
+	state.Set(LED_AP, true)
+
+	for i := 0; i < 200; i++ {
+		panel := NewPanelState()
+		panel.SetPattern(LED_AP, LEDPattern{BlinkHz: 1, Flash: time.Hour})
+
+		go panel.Apply(state)
+
+		// an LED that is on without its start time would look long past its
+		// flash and stop animating
+		for {
+			lit, next := panel.Render(time.Now())
+			if lit.IsOn(LED_AP) {
+				assert.Greater(t, next, time.Duration(0))
+				break
+			}
+		}
+	}
+}
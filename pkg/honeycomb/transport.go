@@ -0,0 +1,65 @@
+package honeycomb
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sstallion/go-hid"
+)
+
+// Device is an open connection to a Bravo throttle quadrant.
+type Device interface {
+	SendFeatureReport(p []byte) (int, error)
+	// ReadWithTimeout waits up to timeout for the next input report. It
+	// returns 0 and no error if nothing arrived in time.
+	ReadWithTimeout(p []byte, timeout time.Duration) (int, error)
+	Close() error
+}
+
+// Transport opens Devices. The bravo service only talks to the hardware
+// through this interface so that the LED pipeline can run against a fake.
+type Transport interface {
+	Open() (Device, error)
+	Exit() error
+}
+
+type hidTransport struct {
+	vendor  uint16
+	product uint16
+}
+
+// NewHIDTransport initializes hidapi and returns a Transport that opens the
+// first device matching the given vendor and product ids.
+func NewHIDTransport(vendor uint16, product uint16) (Transport, error) {
+	if err := hid.Init(); err != nil {
+		return nil, err
+	}
+	return &hidTransport{
+		vendor:  vendor,
+		product: product,
+	}, nil
+}
+
+func (t *hidTransport) Open() (Device, error) {
+	device, err := hid.OpenFirst(t.vendor, t.product)
+	if err != nil {
+		return nil, err
+	}
+	return &hidDevice{device}, nil
+}
+
+func (t *hidTransport) Exit() error {
+	return hid.Exit()
+}
+
+type hidDevice struct {
+	*hid.Device
+}
+
+func (d *hidDevice) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	n, err := d.Device.ReadWithTimeout(p, timeout)
+	if errors.Is(err, hid.ErrTimeout) {
+		return 0, nil
+	}
+	return n, err
+}
@@ -2,9 +2,13 @@ package pkg
 
 import "github.com/expr-lang/expr/vm"
 
+// Command is an X-Plane command. Queued commands are held for Hold seconds,
+// 0 means the default, and run Repeat times, 0 means once.
 type Command struct {
 	CommandStr string      `yaml:"command_str,omitempty" json:"command_str,omitempty"`
 	Command    interface{} `yaml:"-" json:"-"`
+	Hold       float32     `yaml:"hold,omitempty" json:"hold,omitempty"`
+	Repeat     int         `yaml:"repeat,omitempty" json:"repeat,omitempty"`
 }
 
 type Dataref struct {
@@ -13,14 +17,40 @@ type Dataref struct {
 	Index      int         `yaml:"index,omitempty" json:"index,omitempty"`
 }
 
+// IndexRange selects the array elements From to To, both included.
+type IndexRange struct {
+	From int `yaml:"from" json:"from"`
+	To   int `yaml:"to" json:"to"`
+}
+
+// DatarefCondition compares a dataref with Threshold. Array datarefs compare
+// the element at Index, or with Match set, the elements in Range (all of them
+// by default): "any" or "all" of them must pass, or with "count" at least
+// Count of them. String datarefs are compared with Text using the "equals",
+// "contains", "prefix" or "regex" operators. With Hysteresis set, a passing
+// check keeps passing until the value is that far past the threshold.
 type DatarefCondition struct {
 	DatarefStr string                 `yaml:"dataref_str,omitempty" json:"dataref_str,omitempty"`
 	Dataref    interface{}            `yaml:"-" json:"-"`
 	Index      int                    `yaml:"index,omitempty" json:"index,omitempty"`
+	Match      string                 `yaml:"match,omitempty" json:"match,omitempty"`
+	Range      *IndexRange            `yaml:"range,omitempty" json:"range,omitempty"`
+	Count      int                    `yaml:"count,omitempty" json:"count,omitempty"`
 	Operator   string                 `yaml:"operator,omitempty" json:"operator,omitempty"`
 	Threshold  *float32               `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	Text       string                 `yaml:"text,omitempty" json:"text,omitempty"`
+	Hysteresis float32                `yaml:"hysteresis,omitempty" json:"hysteresis,omitempty"`
 	Expr       *vm.Program            `yaml:"-" json:"-"`
+	HoldExpr   *vm.Program            `yaml:"-" json:"-"`
 	Env        map[string]interface{} `yaml:"-" json:"-"`
+	Passing    bool                   `yaml:"-" json:"-"`
+}
+
+// DatarefValue is a dataref write: Value is stored into the dataref, or into
+// the element at Index for array datarefs.
+type DatarefValue struct {
+	Dataref `yaml:",inline"`
+	Value   float32 `yaml:"value" json:"value"`
 }
 
 type Metadata struct {
@@ -29,34 +59,247 @@ type Metadata struct {
 	Selectors   []string `yaml:"selectors,omitempty" json:"selectors,omitempty"`
 }
 
+// ConditionTimer holds back changes of a condition result until they lasted
+// the condition's on or off delay.
+type ConditionTimer struct {
+	Result  bool
+	Pending bool
+	Since   float64
+}
+
+// ConditionProfile is either a list of dataref checks and nested groups
+// combined with Condition ("all", "any" or "none"), or an expression over the
+// datarefs named in Vars, e.g.
+// "(n1[0] > 20 && oil_p[0] < 25) || test_switch == 1".
+// OnDelay and OffDelay are the seconds a new result must last before it is
+// used.
 type ConditionProfile struct {
-	Datarefs  []DatarefCondition `yaml:"datarefs,omitempty" json:"datarefs,omitempty"`
-	Condition string             `yaml:"condition,omitempty" json:"condition,omitempty"`
+	Datarefs  []DatarefCondition     `yaml:"datarefs,omitempty" json:"datarefs,omitempty"`
+	Groups    []ConditionProfile     `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Condition string                 `yaml:"condition,omitempty" json:"condition,omitempty"`
+	Expr      string                 `yaml:"expr,omitempty" json:"expr,omitempty"`
+	Vars      map[string]string      `yaml:"vars,omitempty" json:"vars,omitempty"`
+	OnDelay   float32                `yaml:"on_delay,omitempty" json:"on_delay,omitempty"`
+	OffDelay  float32                `yaml:"off_delay,omitempty" json:"off_delay,omitempty"`
+	Program   *vm.Program            `yaml:"-" json:"-"`
+	Refs      map[string]interface{} `yaml:"-" json:"-"`
+	Env       map[string]interface{} `yaml:"-" json:"-"`
+	Timer     *ConditionTimer        `yaml:"-" json:"-"`
+	// ExprFailed is set once Expr failed to run, so the error is logged once
+	// per profile load.
+	ExprFailed bool `yaml:"-" json:"-"`
 }
 
 type DatarefProfile struct {
 	Datarefs []Dataref `yaml:"datarefs,omitempty" json:"datarefs,omitempty"`
 }
 
+// LEDPatternProfile is how an LED is shown while it is on. Mode is "steady"
+// (the default), "blink" at Hz, or "flash": blink for Duration seconds after
+// turning on, then stay on.
+type LEDPatternProfile struct {
+	Mode     string  `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Hz       float32 `yaml:"hz,omitempty" json:"hz,omitempty"`
+	Duration float32 `yaml:"duration,omitempty" json:"duration,omitempty"`
+}
+
 type LEDProfile struct {
 	ConditionProfile `yaml:",inline"`
-	On               func() `yaml:"-" json:"-"`
-	Off              func() `yaml:"-" json:"-"`
+	Pattern          *LEDPatternProfile `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	On               func()             `yaml:"-" json:"-"`
+	Off              func()             `yaml:"-" json:"-"`
 }
 
+// GearLEDProfile drives the six gear LEDs. Each leg reads a deploy ratio from
+// its own dataref or array index; by default all legs read the first gear
+// dataref at index 0 (nose), 1 (left) and 2 (right). A leg is green at or
+// above DownThreshold, off at or below UpThreshold and red in between. While
+// Unsafe is true, legs that are not down show red as well.
+type GearLEDProfile struct {
+	LEDProfile    `yaml:",inline"`
+	Nose          *Dataref         `yaml:"nose,omitempty" json:"nose,omitempty"`
+	Left          *Dataref         `yaml:"left,omitempty" json:"left,omitempty"`
+	Right         *Dataref         `yaml:"right,omitempty" json:"right,omitempty"`
+	DownThreshold *float32         `yaml:"down_threshold,omitempty" json:"down_threshold,omitempty"`
+	UpThreshold   *float32         `yaml:"up_threshold,omitempty" json:"up_threshold,omitempty"`
+	Unsafe        ConditionProfile `yaml:"unsafe,omitempty" json:"unsafe,omitempty"`
+}
+
+// DataProfile is a value read from the first dataref, or with Aggregate set
+// ("min", "max", "sum", "avg" or "first_non_zero") combined from every dataref
+// and, for array datarefs, every element in Range. The result is multiplied by
+// Scale and Offset is added. Value is used when there are no datarefs.
 type DataProfile struct {
 	DatarefProfile `yaml:",inline"`
-	Value          *float32 `yaml:"value,omitempty" json:"value,omitempty"`
+	Value          *float32    `yaml:"value,omitempty" json:"value,omitempty"`
+	Aggregate      string      `yaml:"aggregate,omitempty" json:"aggregate,omitempty"`
+	Range          *IndexRange `yaml:"range,omitempty" json:"range,omitempty"`
+	Scale          *float32    `yaml:"scale,omitempty" json:"scale,omitempty"`
+	Offset         float32     `yaml:"offset,omitempty" json:"offset,omitempty"`
 }
 
+// KnobLimits keeps a knob value between Min and Max, or with Wrap set, wraps
+// it around from Max to Min (e.g. heading 0..360). RoundTo snaps the value to
+// a multiple of it in the direction the knob is turned.
+type KnobLimits struct {
+	Min     *float32 `yaml:"min,omitempty" json:"min,omitempty"`
+	Max     *float32 `yaml:"max,omitempty" json:"max,omitempty"`
+	Wrap    bool     `yaml:"wrap,omitempty" json:"wrap,omitempty"`
+	RoundTo float32  `yaml:"round_to,omitempty" json:"round_to,omitempty"`
+}
+
+// KnobMachProfile switches a speed knob to Mach while the changeover dataref
+// is not 0. Step and the limits then replace the ones of the knob.
+type KnobMachProfile struct {
+	Dataref    `yaml:",inline"`
+	KnobLimits `yaml:",inline"`
+	Step       float32 `yaml:"step,omitempty" json:"step,omitempty"`
+}
+
+// KnobAccelerationStep multiplies the knob step by Multiplier when the knob
+// is turned again within WithinMs.
+type KnobAccelerationStep struct {
+	WithinMs   int     `yaml:"within_ms" json:"within_ms"`
+	Multiplier float64 `yaml:"multiplier" json:"multiplier"`
+}
+
+// KnobAcceleration makes fast knob turns change the value faster. Steps are
+// checked from the shortest window up. Without steps the multiplier falls
+// from Sensitivity right after the last turn to 1 at WindowMs, like the trim
+// wheels.
+type KnobAcceleration struct {
+	Steps       []KnobAccelerationStep `yaml:"steps,omitempty" json:"steps,omitempty"`
+	Sensitivity *float64               `yaml:"sensitivity,omitempty" json:"sensitivity,omitempty"`
+	WindowMs    *int                   `yaml:"window_ms,omitempty" json:"window_ms,omitempty"`
+}
+
+// KnobCommands are fired when a knob is turned, pushed or pulled. Fast turns
+// fire the coarse commands once, if set, instead of repeating the fine ones.
+type KnobCommands struct {
+	Increase       []Command `yaml:"increase,omitempty" json:"increase,omitempty"`
+	Decrease       []Command `yaml:"decrease,omitempty" json:"decrease,omitempty"`
+	CoarseIncrease []Command `yaml:"coarse_increase,omitempty" json:"coarse_increase,omitempty"`
+	CoarseDecrease []Command `yaml:"coarse_decrease,omitempty" json:"coarse_decrease,omitempty"`
+	Push           []Command `yaml:"push,omitempty" json:"push,omitempty"`
+	Pull           []Command `yaml:"pull,omitempty" json:"pull,omitempty"`
+}
+
+// KnobProfile is what the rotary encoder does in one selector position.
+// Commands is the older [increase, decrease] form of KnobCommands.
 type KnobProfile struct {
 	DatarefProfile `yaml:",inline"`
-	Commands       []Command `yaml:"commands,omitempty" json:"commands,omitempty"`
+	KnobLimits     `yaml:",inline"`
+	KnobCommands   `yaml:",inline"`
+	Commands       []Command         `yaml:"commands,omitempty" json:"commands,omitempty"`
+	Mach           *KnobMachProfile  `yaml:"mach,omitempty" json:"mach,omitempty"`
+	Acceleration   *KnobAcceleration `yaml:"acceleration,omitempty" json:"acceleration,omitempty"`
+}
+
+// ButtonAction is one step of a button press. Without a type CommandStr is
+// queued like before, so plain command lists keep working. The dataref
+// actions use Dataref; increment_dataref also uses Step and the limits.
+//
+// An entry with When and Actions is a binding instead: Actions run only if
+// the condition passes. An entry with only Actions is the fallback for when
+// no binding in the list passed.
+type ButtonAction struct {
+	When       *ConditionProfile `yaml:"when,omitempty" json:"when,omitempty"`
+	Actions    []ButtonAction    `yaml:"actions,omitempty" json:"actions,omitempty"`
+	Type       string            `yaml:"type,omitempty" json:"type,omitempty"`
+	CommandStr string            `yaml:"command_str,omitempty" json:"command_str,omitempty"`
+	Hold       float32           `yaml:"hold,omitempty" json:"hold,omitempty"`
+	Dataref    `yaml:",inline"`
+	Value      float32 `yaml:"value,omitempty" json:"value,omitempty"`
+	Step       float32 `yaml:"step,omitempty" json:"step,omitempty"`
+	KnobLimits `yaml:",inline"`
+	Values     []float32 `yaml:"values,omitempty" json:"values,omitempty"`
+	Seconds    float32   `yaml:"seconds,omitempty" json:"seconds,omitempty"`
+	Repeat     int       `yaml:"repeat,omitempty" json:"repeat,omitempty"`
+	Macro      string    `yaml:"macro,omitempty" json:"macro,omitempty"`
 }
 
+// ButtonProfile is what an AP button does. Hold commands are begun when the
+// button is pressed and ended when it is released.
 type ButtonProfile struct {
-	SingleClick []Command `yaml:"single_click,omitempty" json:"single_click,omitempty"`
-	DoubleClick []Command `yaml:"double_click,omitempty" json:"double_click,omitempty"`
+	SingleClick []ButtonAction `yaml:"single_click,omitempty" json:"single_click,omitempty"`
+	DoubleClick []ButtonAction `yaml:"double_click,omitempty" json:"double_click,omitempty"`
+	LongPress   []ButtonAction `yaml:"long_press,omitempty" json:"long_press,omitempty"`
+	Hold        []Command      `yaml:"hold,omitempty" json:"hold,omitempty"`
+}
+
+// ButtonTiming sets how AP button presses are told apart, in milliseconds.
+type ButtonTiming struct {
+	DoubleClickMs int `yaml:"double_click_ms,omitempty" json:"double_click_ms,omitempty"`
+	LongPressMs   int `yaml:"long_press_ms,omitempty" json:"long_press_ms,omitempty"`
+}
+
+// ActionProfile is what happens when a switch or lever is moved into a
+// position: the commands are fired in order, then the datarefs are written,
+// then the macro is started.
+type ActionProfile struct {
+	Commands []Command      `yaml:"commands,omitempty" json:"commands,omitempty"`
+	Datarefs []DatarefValue `yaml:"datarefs,omitempty" json:"datarefs,omitempty"`
+	Macro    string         `yaml:"macro,omitempty" json:"macro,omitempty"`
+}
+
+type SwitchProfile struct {
+	Up   ActionProfile `yaml:"up,omitempty" json:"up,omitempty"`
+	Down ActionProfile `yaml:"down,omitempty" json:"down,omitempty"`
+}
+
+// GearLeverProfile is what the gear lever does in its up and down positions.
+type GearLeverProfile struct {
+	Up   ActionProfile `yaml:"up,omitempty" json:"up,omitempty"`
+	Down ActionProfile `yaml:"down,omitempty" json:"down,omitempty"`
+}
+
+// FlapLeverProfile is what one click of the spring loaded flap lever does.
+// With detents the flap handle dataref is moved to the next detent ratio,
+// otherwise the up/down actions are run.
+type FlapLeverProfile struct {
+	Up      ActionProfile `yaml:"up,omitempty" json:"up,omitempty"`
+	Down    ActionProfile `yaml:"down,omitempty" json:"down,omitempty"`
+	Dataref `yaml:",inline"`
+	Detents []float32 `yaml:"detents,omitempty" json:"detents,omitempty"`
+}
+
+// LeverRange is a min/max pair. The zero value means the full 0..1 range.
+type LeverRange struct {
+	Min float32 `yaml:"min" json:"min"`
+	Max float32 `yaml:"max" json:"max"`
+}
+
+// LeverDetent snaps the lever to Position while it is within Width of it.
+// Value, if set, is written instead of Position, e.g. the exact FLX ratio
+// an airliner expects.
+type LeverDetent struct {
+	Position float32  `yaml:"position" json:"position"`
+	Width    float32  `yaml:"width,omitempty" json:"width,omitempty"`
+	Value    *float32 `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// LeverReverse turns the throttle into a reverser while the lever's reverse
+// detent button is pressed. Max limits the reverse ratio, 0 means 1.
+type LeverReverse struct {
+	Enabled bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Max     float32 `yaml:"max,omitempty" json:"max,omitempty"`
+}
+
+// LeverProfile assigns one Bravo lever (1-6 from left to right) to a role.
+// The position goes through calibration, dead zone, detents and curve before
+// it is scaled to Output and written to every target dataref.
+type LeverProfile struct {
+	Lever       int    `yaml:"lever" json:"lever"`
+	Role        string `yaml:"role" json:"role"`
+	Engines     []int  `yaml:"engines,omitempty" json:"engines,omitempty"`
+	Dataref     `yaml:",inline"`
+	Calibration LeverRange    `yaml:"calibration,omitempty" json:"calibration,omitempty"`
+	Output      LeverRange    `yaml:"output,omitempty" json:"output,omitempty"`
+	DeadZone    float32       `yaml:"dead_zone,omitempty" json:"dead_zone,omitempty"`
+	Curve       float32       `yaml:"curve,omitempty" json:"curve,omitempty"`
+	Reverse     LeverReverse  `yaml:"reverse,omitempty" json:"reverse,omitempty"`
+	Detents     []LeverDetent `yaml:"detents,omitempty" json:"detents,omitempty"`
+	Targets     []Dataref     `yaml:"-" json:"-"`
 }
 
 type Knobs struct {
@@ -68,29 +311,29 @@ type Knobs struct {
 }
 
 type Leds struct {
-	HDG                LEDProfile `yaml:"hdg,omitempty" json:"hdg,omitempty"`
-	NAV                LEDProfile `yaml:"nav,omitempty" json:"nav,omitempty"`
-	ALT                LEDProfile `yaml:"alt,omitempty" json:"alt,omitempty"`
-	APR                LEDProfile `yaml:"apr,omitempty" json:"apr,omitempty"`
-	VS                 LEDProfile `yaml:"vs,omitempty" json:"vs,omitempty"`
-	AP                 LEDProfile `yaml:"ap,omitempty" json:"ap,omitempty"`
-	IAS                LEDProfile `yaml:"ias,omitempty" json:"ias,omitempty"`
-	REV                LEDProfile `yaml:"rev,omitempty" json:"rev,omitempty"`
-	GEAR               LEDProfile `yaml:"gear,omitempty" json:"gear,omitempty"`
-	MASTER_WARN        LEDProfile `yaml:"master_warn,omitempty" json:"master_warn,omitempty"`
-	MASTER_CAUTION     LEDProfile `yaml:"master_caution,omitempty" json:"master_caution,omitempty"`
-	FIRE               LEDProfile `yaml:"fire,omitempty" json:"fire,omitempty"`
-	OIL_LOW_PRESSURE   LEDProfile `yaml:"oil_low_pressure,omitempty" json:"oil_low_pressure,omitempty"`
-	FUEL_LOW_PRESSURE  LEDProfile `yaml:"fuel_low_pressure,omitempty" json:"fuel_low_pressure,omitempty"`
-	ANTI_ICE           LEDProfile `yaml:"anti_ice,omitempty" json:"anti_ice,omitempty"`
-	ENG_STARTER        LEDProfile `yaml:"eng_starter,omitempty" json:"eng_starter,omitempty"`
-	APU                LEDProfile `yaml:"apu,omitempty" json:"apu,omitempty"`
-	VACUUM             LEDProfile `yaml:"vacuum,omitempty" json:"vacuum,omitempty"`
-	HYDRO_LOW_PRESSURE LEDProfile `yaml:"hydro_low_pressure,omitempty" json:"hydro_low_pressure,omitempty"`
-	AUX_FUEL_PUMP      LEDProfile `yaml:"aux_fuel_pump,omitempty" json:"aux_fuel_pump,omitempty"`
-	PARKING_BRAKE      LEDProfile `yaml:"parking_brake,omitempty" json:"parking_brake,omitempty"`
-	VOLT_LOW           LEDProfile `yaml:"volt_low,omitempty" json:"volt_low,omitempty"`
-	DOORS              LEDProfile `yaml:"doors,omitempty" json:"doors,omitempty"`
+	HDG                LEDProfile     `yaml:"hdg,omitempty" json:"hdg,omitempty"`
+	NAV                LEDProfile     `yaml:"nav,omitempty" json:"nav,omitempty"`
+	ALT                LEDProfile     `yaml:"alt,omitempty" json:"alt,omitempty"`
+	APR                LEDProfile     `yaml:"apr,omitempty" json:"apr,omitempty"`
+	VS                 LEDProfile     `yaml:"vs,omitempty" json:"vs,omitempty"`
+	AP                 LEDProfile     `yaml:"ap,omitempty" json:"ap,omitempty"`
+	IAS                LEDProfile     `yaml:"ias,omitempty" json:"ias,omitempty"`
+	REV                LEDProfile     `yaml:"rev,omitempty" json:"rev,omitempty"`
+	GEAR               GearLEDProfile `yaml:"gear,omitempty" json:"gear,omitempty"`
+	MASTER_WARN        LEDProfile     `yaml:"master_warn,omitempty" json:"master_warn,omitempty"`
+	MASTER_CAUTION     LEDProfile     `yaml:"master_caution,omitempty" json:"master_caution,omitempty"`
+	FIRE               LEDProfile     `yaml:"fire,omitempty" json:"fire,omitempty"`
+	OIL_LOW_PRESSURE   LEDProfile     `yaml:"oil_low_pressure,omitempty" json:"oil_low_pressure,omitempty"`
+	FUEL_LOW_PRESSURE  LEDProfile     `yaml:"fuel_low_pressure,omitempty" json:"fuel_low_pressure,omitempty"`
+	ANTI_ICE           LEDProfile     `yaml:"anti_ice,omitempty" json:"anti_ice,omitempty"`
+	ENG_STARTER        LEDProfile     `yaml:"eng_starter,omitempty" json:"eng_starter,omitempty"`
+	APU                LEDProfile     `yaml:"apu,omitempty" json:"apu,omitempty"`
+	VACUUM             LEDProfile     `yaml:"vacuum,omitempty" json:"vacuum,omitempty"`
+	HYDRO_LOW_PRESSURE LEDProfile     `yaml:"hydro_low_pressure,omitempty" json:"hydro_low_pressure,omitempty"`
+	AUX_FUEL_PUMP      LEDProfile     `yaml:"aux_fuel_pump,omitempty" json:"aux_fuel_pump,omitempty"`
+	PARKING_BRAKE      LEDProfile     `yaml:"parking_brake,omitempty" json:"parking_brake,omitempty"`
+	VOLT_LOW           LEDProfile     `yaml:"volt_low,omitempty" json:"volt_low,omitempty"`
+	DOORS              LEDProfile     `yaml:"doors,omitempty" json:"doors,omitempty"`
 }
 
 type Data struct {
@@ -127,12 +370,30 @@ type Buttons struct {
 	REV ButtonProfile `yaml:"rev,omitempty" json:"rev,omitempty"`
 }
 
+// Switches are the seven toggle switches at the bottom of the Bravo, from left
+// to right.
+type Switches struct {
+	SWITCH_1 SwitchProfile `yaml:"switch_1,omitempty" json:"switch_1,omitempty"`
+	SWITCH_2 SwitchProfile `yaml:"switch_2,omitempty" json:"switch_2,omitempty"`
+	SWITCH_3 SwitchProfile `yaml:"switch_3,omitempty" json:"switch_3,omitempty"`
+	SWITCH_4 SwitchProfile `yaml:"switch_4,omitempty" json:"switch_4,omitempty"`
+	SWITCH_5 SwitchProfile `yaml:"switch_5,omitempty" json:"switch_5,omitempty"`
+	SWITCH_6 SwitchProfile `yaml:"switch_6,omitempty" json:"switch_6,omitempty"`
+	SWITCH_7 SwitchProfile `yaml:"switch_7,omitempty" json:"switch_7,omitempty"`
+}
+
 type Profile struct {
-	Metadata   *Metadata   `yaml:"metadata" json:"metadata"`
-	Buttons    *Buttons    `yaml:"buttons,omitempty" json:"buttons,omitempty"`
-	Knobs      *Knobs      `yaml:"knobs,omitempty" json:"knobs,omitempty"`
-	Leds       *Leds       `yaml:"leds,omitempty" json:"leds,omitempty"`
-	Data       *Data       `yaml:"data,omitempty" json:"data,omitempty"`
-	TrimWheels *TrimWheels `yaml:"trim_wheels,omitempty" json:"trim_wheels,omitempty"`
-	Conditions *Conditions `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+	Metadata   *Metadata                 `yaml:"metadata" json:"metadata"`
+	Buttons    *Buttons                  `yaml:"buttons,omitempty" json:"buttons,omitempty"`
+	Timing     *ButtonTiming             `yaml:"button_timing,omitempty" json:"button_timing,omitempty"`
+	Knobs      *Knobs                    `yaml:"knobs,omitempty" json:"knobs,omitempty"`
+	Leds       *Leds                     `yaml:"leds,omitempty" json:"leds,omitempty"`
+	Data       *Data                     `yaml:"data,omitempty" json:"data,omitempty"`
+	TrimWheels *TrimWheels               `yaml:"trim_wheels,omitempty" json:"trim_wheels,omitempty"`
+	Conditions *Conditions               `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+	Switches   *Switches                 `yaml:"switches,omitempty" json:"switches,omitempty"`
+	GearLever  *GearLeverProfile         `yaml:"gear_lever,omitempty" json:"gear_lever,omitempty"`
+	FlapLever  *FlapLeverProfile         `yaml:"flap_lever,omitempty" json:"flap_lever,omitempty"`
+	Levers     []LeverProfile            `yaml:"levers,omitempty" json:"levers,omitempty"`
+	Macros     map[string][]ButtonAction `yaml:"macros,omitempty" json:"macros,omitempty"`
 }
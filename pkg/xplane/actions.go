@@ -0,0 +1,61 @@
+package xplane
+
+import (
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+// runAction queues the commands of an action for the flight loop, writes
+// its datarefs right away and starts its macro.
+func (s *xplaneService) runAction(name string, action *pkg.ActionProfile) {
+	if len(action.Commands) == 0 && len(action.Datarefs) == 0 && action.Macro == "" {
+		s.Logger.Debugf("No action configured for: %s", name)
+		return
+	}
+
+	if len(action.Commands) > 0 {
+		s.cmdEventQueueMu.Lock()
+		for _, cmd := range action.Commands {
+			s.cmdEventQueue = append(s.cmdEventQueue, queuedCommandOf(cmd))
+		}
+		s.cmdEventQueueMu.Unlock()
+	}
+
+	for i := range action.Datarefs {
+		s.writeDatarefValue(&action.Datarefs[i])
+	}
+
+	if action.Macro != "" {
+		s.startMacro(action.Macro)
+	}
+}
+
+// writeDatarefValue stores the configured value into the dataref. For array
+// datarefs only the element at Index is changed.
+func (s *xplaneService) writeDatarefValue(value *pkg.DatarefValue) {
+	handle := datarefOf(value.Dataref.Dataref)
+	if handle == nil {
+		s.Logger.Errorf("Dataref not found: %s", value.DatarefStr)
+		return
+	}
+
+	if err := handle.set(value.Index, float64(value.Value)); err != nil {
+		s.Logger.Errorf("%v", err)
+	}
+}
+
+// readDatarefValue returns the current value of the dataref, or of the element
+// at Index for array datarefs.
+func (s *xplaneService) readDatarefValue(dataref *pkg.Dataref) (float64, bool) {
+	handle := datarefOf(dataref.Dataref)
+	if handle == nil {
+		s.Logger.Errorf("Dataref not found: %s", dataref.DatarefStr)
+		return 0.0, false
+	}
+
+	value, err := handle.get(dataref.Index)
+	if err != nil {
+		s.Logger.Errorf("%v", err)
+		return 0.0, false
+	}
+	return value, true
+}
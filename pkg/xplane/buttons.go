@@ -0,0 +1,361 @@
+package xplane
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/xairline/goplane/xplm/utilities"
+)
+
+const (
+	buttonActionCommand          = "command"
+	buttonActionCommandOnce      = "command_once"
+	buttonActionCommandHold      = "command_hold"
+	buttonActionSetDataref       = "set_dataref"
+	buttonActionToggleDataref    = "toggle_dataref"
+	buttonActionIncrementDataref = "increment_dataref"
+	buttonActionCycleValues      = "cycle_values"
+)
+
+const (
+	buttonGestureSingleClick = "single_click"
+	buttonGestureDoubleClick = "double_click"
+	buttonGestureLongPress   = "long_press"
+)
+
+func (s *xplaneService) loadButtonProfile(fieldName string, fieldValue *pkg.ButtonProfile) error {
+	err := s.loadButtonActions(fieldName+".single_click", fieldValue.SingleClick, false)
+	if err != nil {
+		return err
+	}
+	err = s.loadButtonActions(fieldName+".double_click", fieldValue.DoubleClick, false)
+	if err != nil {
+		return err
+	}
+	err = s.loadButtonActions(fieldName+".long_press", fieldValue.LongPress, false)
+	if err != nil {
+		return err
+	}
+	for i, cmd := range fieldValue.Hold {
+		if cmd.CommandStr == "" {
+			return fmt.Errorf("%s.hold[%d]: missing command_str", fieldName, i)
+		}
+	}
+	return nil
+}
+
+func validateButtonTiming(timing *pkg.ButtonTiming) error {
+	if timing.DoubleClickMs < 0 {
+		return fmt.Errorf("double_click_ms must not be negative, got %d", timing.DoubleClickMs)
+	}
+	if timing.LongPressMs < 0 {
+		return fmt.Errorf("long_press_ms must not be negative, got %d", timing.LongPressMs)
+	}
+	return nil
+}
+
+// buttonTimingOf returns the double click window and the long press
+// threshold, using the defaults for anything not set.
+func buttonTimingOf(timing *pkg.ButtonTiming) (time.Duration, time.Duration) {
+	doubleClick, longPress := doubleClickThreshold, longPressThreshold
+	if timing == nil {
+		return doubleClick, longPress
+	}
+	if timing.DoubleClickMs > 0 {
+		doubleClick = time.Duration(timing.DoubleClickMs) * time.Millisecond
+	}
+	if timing.LongPressMs > 0 {
+		longPress = time.Duration(timing.LongPressMs) * time.Millisecond
+	}
+	return doubleClick, longPress
+}
+
+func (s *xplaneService) profileButtonTiming() *pkg.ButtonTiming {
+	if s.profile == nil {
+		return nil
+	}
+	return s.profile.Timing
+}
+
+// hasClickActions tells if a button does anything when it is released.
+func hasClickActions(btn *pkg.ButtonProfile) bool {
+	return len(btn.SingleClick) > 0 || len(btn.DoubleClick) > 0 || len(btn.LongPress) > 0
+}
+
+// beginHeldButton begins the hold commands of a button until it is
+// released. Callers hold s.mutex.
+func (s *xplaneService) beginHeldButton(ref string, commands []pkg.Command) {
+	s.endHeldButton(ref)
+	var begun []string
+	for _, command := range commands {
+		cmd := utilities.FindCommand(command.CommandStr)
+		if cmd == nil {
+			s.Logger.Errorf("Command not found: %s", command.CommandStr)
+			continue
+		}
+		s.Logger.Debugf("Beginning hold command: %s", command.CommandStr)
+		utilities.CommandBegin(cmd)
+		begun = append(begun, command.CommandStr)
+	}
+	s.heldButtons[ref] = begun
+}
+
+// endHeldButton ends the hold commands begun for a button, in reverse order.
+// Callers hold s.mutex.
+func (s *xplaneService) endHeldButton(ref string) {
+	begun := s.heldButtons[ref]
+	delete(s.heldButtons, ref)
+	for i := len(begun) - 1; i >= 0; i-- {
+		cmd := utilities.FindCommand(begun[i])
+		if cmd == nil {
+			continue
+		}
+		s.Logger.Debugf("Ending hold command: %s", begun[i])
+		utilities.CommandEnd(cmd)
+	}
+}
+
+// releaseHeldButtons ends every hold command, e.g. when another profile is
+// loaded while a button is held.
+func (s *xplaneService) releaseHeldButtons() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ref := range s.heldButtons {
+		s.endHeldButton(ref)
+	}
+	s.pressTimes = make(map[string]time.Time)
+}
+
+// loadButtonActions checks and resolves a list of actions. The macro only
+// steps are allowed when inMacro is set.
+func (s *xplaneService) loadButtonActions(fieldName string, actions []pkg.ButtonAction, inMacro bool) error {
+	fallbacks := 0
+	for i := range actions {
+		action := &actions[i]
+		if isButtonBinding(action) {
+			name := fmt.Sprintf("%s[%d]", fieldName, i)
+			if err := validateButtonBinding(action); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			if action.When == nil {
+				fallbacks++
+				if fallbacks > 1 {
+					return fmt.Errorf("%s: only one fallback is allowed", name)
+				}
+			} else if err := s.loadConditionProfile(name+".when", action.When); err != nil {
+				return err
+			}
+			if err := s.loadButtonActions(name+".actions", action.Actions, inMacro); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validateButtonAction(action); err != nil {
+			return fmt.Errorf("%s[%d]: %v", fieldName, i, err)
+		}
+		if !inMacro && isMacroStep(action) {
+			return fmt.Errorf("%s[%d]: %s can only be used in macros", fieldName, i, action.Type)
+		}
+		if action.Type == buttonActionMacro {
+			if err := s.checkMacroRef(action.Macro); err != nil {
+				return fmt.Errorf("%s[%d]: %v", fieldName, i, err)
+			}
+		}
+		if action.DatarefStr != "" {
+			action.Dataref.Dataref = s.getDataref(action.DatarefStr)
+		}
+	}
+	return nil
+}
+
+// isButtonBinding tells a conditional entry from a plain action.
+func isButtonBinding(action *pkg.ButtonAction) bool {
+	return action.When != nil || len(action.Actions) > 0
+}
+
+func validateButtonBinding(action *pkg.ButtonAction) error {
+	if len(action.Actions) == 0 {
+		return fmt.Errorf("when needs actions")
+	}
+	if action.Type != "" || action.CommandStr != "" || action.DatarefStr != "" {
+		return fmt.Errorf("a binding can only have when and actions")
+	}
+	return nil
+}
+
+// selectButtonActions returns the actions of a press: plain actions always
+// run, and of the bindings only the first one whose condition passes, or the
+// fallback if none does.
+func selectButtonActions(actions []pkg.ButtonAction, passes func(*pkg.ConditionProfile) bool) []pkg.ButtonAction {
+	var selected []pkg.ButtonAction
+	var fallback []pkg.ButtonAction
+	matched := false
+	for _, action := range actions {
+		if !isButtonBinding(&action) {
+			selected = append(selected, action)
+			continue
+		}
+		if action.When == nil {
+			fallback = action.Actions
+			continue
+		}
+		if !matched && passes(action.When) {
+			matched = true
+			selected = append(selected, selectButtonActions(action.Actions, passes)...)
+		}
+	}
+	if !matched && fallback != nil {
+		selected = append(selected, selectButtonActions(fallback, passes)...)
+	}
+	return selected
+}
+
+// runButtonActions runs the actions of one button press. Binding conditions
+// are checked right now, without their delays.
+func (s *xplaneService) runButtonActions(actions []pkg.ButtonAction) {
+	passes := func(condition *pkg.ConditionProfile) bool {
+		result, valid := s.evaluateConditionRules(condition)
+		return valid && result
+	}
+	selected := selectButtonActions(actions, passes)
+	for i := range selected {
+		s.runButtonAction(&selected[i])
+	}
+}
+
+// validateButtonAction checks that an action has what its type needs. A
+// missing type is set to command.
+func validateButtonAction(action *pkg.ButtonAction) error {
+	if action.Type == "" {
+		action.Type = buttonActionCommand
+	}
+
+	switch action.Type {
+	case buttonActionMacro:
+		if action.Macro == "" {
+			return fmt.Errorf("macro needs a macro name")
+		}
+		return nil
+	case buttonActionWait:
+		if action.Seconds <= 0 {
+			return fmt.Errorf("wait needs seconds above 0, got %v", action.Seconds)
+		}
+		return nil
+	case buttonActionCommand, buttonActionCommandOnce, buttonActionCommandHold, buttonActionCommandBegin, buttonActionCommandEnd:
+		if action.CommandStr == "" {
+			return fmt.Errorf("%s needs a command_str", action.Type)
+		}
+		if action.Type == buttonActionCommandHold && action.Hold <= 0 {
+			return fmt.Errorf("command_hold needs a hold above 0, got %v", action.Hold)
+		}
+		return validateQueuedCommand(pkg.Command{CommandStr: action.CommandStr, Hold: action.Hold, Repeat: action.Repeat})
+	case buttonActionSetDataref, buttonActionToggleDataref:
+	case buttonActionIncrementDataref:
+		if action.Step == 0 {
+			return fmt.Errorf("increment_dataref needs a step")
+		}
+		if err := validateKnobLimits(action.KnobLimits); err != nil {
+			return err
+		}
+	case buttonActionCycleValues:
+		if len(action.Values) < 2 {
+			return fmt.Errorf("cycle_values needs at least 2 values, got %d", len(action.Values))
+		}
+	default:
+		return fmt.Errorf("Unsupported action type: %s", action.Type)
+	}
+
+	if action.DatarefStr == "" {
+		return fmt.Errorf("%s needs a dataref_str", action.Type)
+	}
+	return nil
+}
+
+// isMacroStep tells if an action only makes sense as a step of a macro.
+func isMacroStep(action *pkg.ButtonAction) bool {
+	switch action.Type {
+	case buttonActionWait, buttonActionCommandBegin, buttonActionCommandEnd:
+		return true
+	}
+	return false
+}
+
+// runButtonAction runs one button action. It is called from the flight loop,
+// so datarefs and commands are used on X-Plane's thread.
+func (s *xplaneService) runButtonAction(action *pkg.ButtonAction) {
+	switch action.Type {
+	case "", buttonActionCommand, buttonActionCommandHold:
+		s.queueCommand(queuedCommandOf(pkg.Command{CommandStr: action.CommandStr, Hold: action.Hold, Repeat: action.Repeat}))
+		return
+	case buttonActionMacro:
+		s.startMacro(action.Macro)
+		return
+	case buttonActionCommandOnce:
+		cmd := utilities.FindCommand(action.CommandStr)
+		if cmd == nil {
+			s.Logger.Errorf("Command not found: %s", action.CommandStr)
+			return
+		}
+		s.Logger.Debugf("Running command once: %s", action.CommandStr)
+		for i := 0; i < max(action.Repeat, 1); i++ {
+			utilities.CommandOnce(cmd)
+		}
+		return
+	}
+
+	handle := datarefOf(action.Dataref.Dataref)
+	if handle == nil {
+		s.Logger.Errorf("Dataref not found: %s", action.DatarefStr)
+		return
+	}
+	current := 0.0
+	if action.Type != buttonActionSetDataref {
+		var err error
+		current, err = handle.get(action.Index)
+		if err != nil {
+			s.Logger.Errorf("%v", err)
+			return
+		}
+	}
+
+	value := buttonActionValue(action, current)
+	s.Logger.Debugf("Button %s: %s[%d], Current Value: %f, New Value: %f", action.Type, action.DatarefStr, action.Index, current, value)
+	if err := handle.set(action.Index, value); err != nil {
+		s.Logger.Errorf("%v", err)
+	}
+}
+
+// buttonActionValue returns the value a dataref action writes when the
+// dataref holds current.
+func buttonActionValue(action *pkg.ButtonAction, current float64) float64 {
+	switch action.Type {
+	case buttonActionToggleDataref:
+		if current != 0 {
+			return 0
+		}
+		if action.Value != 0 {
+			return float64(action.Value)
+		}
+		return 1
+	case buttonActionIncrementDataref:
+		return knobValue(current, float64(action.Step), action.KnobLimits)
+	case buttonActionCycleValues:
+		return nextCycleValue(current, action.Values)
+	}
+	return float64(action.Value)
+}
+
+// nextCycleValue returns the value after the one closest to current, going
+// back to the first after the last.
+func nextCycleValue(current float64, values []float32) float64 {
+	closest := 0
+	for i, value := range values {
+		if math.Abs(float64(value)-current) < math.Abs(float64(values[closest])-current) {
+			closest = i
+		}
+	}
+	return float64(values[(closest+1)%len(values)])
+}
@@ -0,0 +1,125 @@
+package xplane
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func TestValidateButtonAction(t *testing.T) {
+	plain := &pkg.ButtonAction{CommandStr: "sim/autopilot/heading"}
+	assert.NoError(t, validateButtonAction(plain))
+	assert.Equal(t, buttonActionCommand, plain.Type)
+
+	valid := []pkg.ButtonAction{
+		{Type: buttonActionCommandOnce, CommandStr: "sim/autopilot/heading"},
+		{Type: buttonActionCommandHold, CommandStr: "sim/autopilot/heading", Hold: 1},
+		{Type: buttonActionSetDataref, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}},
+		{Type: buttonActionToggleDataref, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}},
+		{Type: buttonActionIncrementDataref, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}, Step: 1},
+		{Type: buttonActionCycleValues, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}, Values: []float32{0, 1, 2}},
+	}
+	for i := range valid {
+		assert.NoError(t, validateButtonAction(&valid[i]), valid[i].Type)
+	}
+
+	invalid := []pkg.ButtonAction{
+		{},
+		{Type: "press"},
+		{Type: buttonActionCommandHold, CommandStr: "sim/autopilot/heading"},
+		{Type: buttonActionSetDataref},
+		{Type: buttonActionIncrementDataref, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}},
+		{Type: buttonActionIncrementDataref, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}, Step: 1, KnobLimits: pkg.KnobLimits{Wrap: true}},
+		{Type: buttonActionCycleValues, Dataref: pkg.Dataref{DatarefStr: "ap/mode"}, Values: []float32{1}},
+	}
+	for i := range invalid {
+		assert.Error(t, validateButtonAction(&invalid[i]), "action %d", i)
+	}
+}
+
+func TestButtonActionValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  pkg.ButtonAction
+		current float64
+		want    float64
+	}{
+		{"set", pkg.ButtonAction{Type: buttonActionSetDataref, Value: 3}, 1, 3},
+		{"toggle on", pkg.ButtonAction{Type: buttonActionToggleDataref}, 0, 1},
+		{"toggle on to value", pkg.ButtonAction{Type: buttonActionToggleDataref, Value: 2}, 0, 2},
+		{"toggle off", pkg.ButtonAction{Type: buttonActionToggleDataref, Value: 2}, 2, 0},
+		{"increment", pkg.ButtonAction{Type: buttonActionIncrementDataref, Step: 1}, 2, 3},
+		{"increment wraps", pkg.ButtonAction{Type: buttonActionIncrementDataref, Step: 1, KnobLimits: pkg.KnobLimits{Min: float32Ptr(0), Max: float32Ptr(4), Wrap: true}}, 3, 0},
+		{"increment below round_to", pkg.ButtonAction{Type: buttonActionIncrementDataref, Step: 10, KnobLimits: pkg.KnobLimits{RoundTo: 100}}, 1000, 1100},
+		{"decrement clamps", pkg.ButtonAction{Type: buttonActionIncrementDataref, Step: -1, KnobLimits: pkg.KnobLimits{Min: float32Ptr(0)}}, 0, 0},
+		{"cycle", pkg.ButtonAction{Type: buttonActionCycleValues, Values: []float32{0, 1, 3}}, 1, 3},
+		{"cycle wraps", pkg.ButtonAction{Type: buttonActionCycleValues, Values: []float32{0, 1, 3}}, 3, 0},
+		{"cycle from unknown value", pkg.ButtonAction{Type: buttonActionCycleValues, Values: []float32{0, 1, 3}}, 0.9, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, buttonActionValue(&tt.action, tt.current))
+		})
+	}
+}
+
+func TestButtonTimingOf(t *testing.T) {
+	doubleClick, longPress := buttonTimingOf(nil)
+	assert.Equal(t, 500*time.Millisecond, doubleClick)
+	assert.Equal(t, 800*time.Millisecond, longPress)
+
+	doubleClick, longPress = buttonTimingOf(&pkg.ButtonTiming{DoubleClickMs: 300})
+	assert.Equal(t, 300*time.Millisecond, doubleClick)
+	assert.Equal(t, 800*time.Millisecond, longPress)
+
+	doubleClick, longPress = buttonTimingOf(&pkg.ButtonTiming{LongPressMs: 1200})
+	assert.Equal(t, 500*time.Millisecond, doubleClick)
+	assert.Equal(t, 1200*time.Millisecond, longPress)
+
+	assert.Error(t, validateButtonTiming(&pkg.ButtonTiming{DoubleClickMs: -1}))
+	assert.Error(t, validateButtonTiming(&pkg.ButtonTiming{LongPressMs: -1}))
+}
+
+func TestHasClickActions(t *testing.T) {
+	action := []pkg.ButtonAction{{CommandStr: "sim/autopilot/heading"}}
+	assert.False(t, hasClickActions(&pkg.ButtonProfile{Hold: []pkg.Command{{CommandStr: "sim/engines/TOGA_power"}}}))
+	assert.True(t, hasClickActions(&pkg.ButtonProfile{SingleClick: action}))
+	assert.True(t, hasClickActions(&pkg.ButtonProfile{DoubleClick: action}))
+	assert.True(t, hasClickActions(&pkg.ButtonProfile{LongPress: action}))
+}
+
+func TestSelectButtonActions(t *testing.T) {
+	hdgActive := &pkg.ConditionProfile{Expr: "hdg_active"}
+	machActive := &pkg.ConditionProfile{Expr: "mach_active"}
+	sync := pkg.ButtonAction{CommandStr: "sim/autopilot/heading_sync"}
+	engage := pkg.ButtonAction{CommandStr: "sim/autopilot/heading"}
+	always := pkg.ButtonAction{CommandStr: "sim/annunciator/clear_master_caution"}
+	actions := []pkg.ButtonAction{
+		always,
+		{When: hdgActive, Actions: []pkg.ButtonAction{sync}},
+		{When: machActive, Actions: []pkg.ButtonAction{{CommandStr: "sim/autopilot/knots_mach_toggle"}}},
+		{Actions: []pkg.ButtonAction{engage}},
+	}
+
+	passing := map[*pkg.ConditionProfile]bool{}
+	passes := func(condition *pkg.ConditionProfile) bool {
+		return passing[condition]
+	}
+
+	assert.Equal(t, []pkg.ButtonAction{always, engage}, selectButtonActions(actions, passes))
+
+	passing[hdgActive] = true
+	passing[machActive] = true
+	assert.Equal(t, []pkg.ButtonAction{always, sync}, selectButtonActions(actions, passes))
+
+	assert.Empty(t, selectButtonActions(actions[1:3], func(*pkg.ConditionProfile) bool { return false }))
+}
+
+func TestValidateButtonBinding(t *testing.T) {
+	when := &pkg.ConditionProfile{Expr: "true"}
+	assert.NoError(t, validateButtonBinding(&pkg.ButtonAction{When: when, Actions: []pkg.ButtonAction{{CommandStr: "a"}}}))
+	assert.Error(t, validateButtonBinding(&pkg.ButtonAction{When: when}))
+	assert.Error(t, validateButtonBinding(&pkg.ButtonAction{When: when, CommandStr: "a", Actions: []pkg.ButtonAction{{CommandStr: "a"}}}))
+}
@@ -2,15 +2,18 @@ package xplane
 
 import "C"
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/x-z7a/zoal-honeycomb/pkg"
-	"github.com/xairline/goplane/xplm/dataAccess"
 	"github.com/xairline/goplane/xplm/utilities"
 )
 
-const doubleClickThreshold = 500 * time.Millisecond // Define double-click threshold
+const (
+	doubleClickThreshold = 500 * time.Millisecond // Define double-click threshold
+	longPressThreshold   = 800 * time.Millisecond
+)
 
 const (
 	defaultTrimUpCommand   = "sim/flight_controls/pitch_trim_up_mech"
@@ -20,6 +23,14 @@ const (
 	minimumTrimSensitivity = 1.0
 	minimumTrimWindowMs    = int64(1)
 	tolissTrimIdleTimeout  = 200 * time.Millisecond
+
+	defaultGearUpCommand    = "sim/flight_controls/landing_gear_up"
+	defaultGearDownCommand  = "sim/flight_controls/landing_gear_down"
+	defaultFlapsUpCommand   = "sim/flight_controls/flaps_up"
+	defaultFlapsDownCommand = "sim/flight_controls/flaps_down"
+	// flapDetentTolerance is how close the flap handle has to be to a detent
+	// to count as sitting in it.
+	flapDetentTolerance = 0.01
 )
 
 func (s *xplaneService) changeApValue(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
@@ -27,32 +38,10 @@ func (s *xplaneService) changeApValue(command utilities.CommandRef, phase utilit
 	if phase == utilities.Phase_CommandEnd {
 		now := time.Now()
 		elapsed := now.Sub(s.lastKnobTime).Milliseconds()
-		// Determine speed multiplier based on time elapsed
-		var multiplier float64
-		if !s.lastKnobTime.IsZero() {
-
-			if elapsed < 100 {
-				multiplier = 5.0 // Fast turn
-			} else if elapsed < 200 {
-				multiplier = 3.0 // Medium turn
-			} else {
-				multiplier = 1.0 // Slow turn
-			}
-		} else {
-			multiplier = 1.0
-		}
 
-		direction := 0
-		// Log the adjustment
-		if ref.(string) == "up" {
-			s.Logger.Debugf("Increase: %v, Phase: %v, AP Mode: %s, Multiplier: %.1f", command, phase, s.apSelector, multiplier)
-			direction = 1
-		} else {
-			s.Logger.Debugf("Decrease: %v, Phase: %v, AP Mode: %s, Multiplier: %.1f", command, phase, s.apSelector, multiplier)
-			direction = -1
-		}
 		var myProfile pkg.KnobProfile
 		var step float64
+		acceleration := &defaultKnobAcceleration
 		switch s.apSelector {
 		case "ias":
 			myProfile = s.profile.Knobs.AP_IAS
@@ -72,11 +61,7 @@ func (s *xplaneService) changeApValue(command utilities.CommandRef, phase utilit
 			} else {
 				step = 100
 			}
-			if elapsed < 100 {
-				multiplier *= 5
-			} else if elapsed < 200 {
-				multiplier *= 2
-			}
+			acceleration = &defaultAltKnobAcceleration
 		case "vs":
 			myProfile = s.profile.Knobs.AP_VS
 
@@ -93,6 +78,21 @@ func (s *xplaneService) changeApValue(command utilities.CommandRef, phase utilit
 			myProfile = s.profile.Knobs.AP_CRS
 			step = 1
 		}
+		if myProfile.Acceleration != nil {
+			acceleration = myProfile.Acceleration
+		}
+		// Determine speed multiplier based on time elapsed
+		multiplier := knobMultiplier(acceleration, elapsed, s.lastKnobTime.IsZero())
+
+		direction := 0
+		// Log the adjustment
+		if ref.(string) == "up" {
+			s.Logger.Debugf("Increase: %v, Phase: %v, AP Mode: %s, Multiplier: %.1f", command, phase, s.apSelector, multiplier)
+			direction = 1
+		} else {
+			s.Logger.Debugf("Decrease: %v, Phase: %v, AP Mode: %s, Multiplier: %.1f", command, phase, s.apSelector, multiplier)
+			direction = -1
+		}
 		s.adjust(myProfile, direction, multiplier, step)
 		s.Logger.Debugf("Knob turn: %d, Mode: %s, Multiplier: %.1f, Step: %.1f", direction, s.apSelector, multiplier, step)
 		// Update the last interaction time
@@ -109,38 +109,73 @@ func (s *xplaneService) changeAPMode(command utilities.CommandRef, phase utiliti
 	return 0
 }
 
+// selectedKnob returns the knob profile for the current selector position.
+func (s *xplaneService) selectedKnob() *pkg.KnobProfile {
+	switch s.apSelector {
+	case "ias":
+		return &s.profile.Knobs.AP_IAS
+	case "alt":
+		return &s.profile.Knobs.AP_ALT
+	case "vs":
+		return &s.profile.Knobs.AP_VS
+	case "hdg":
+		return &s.profile.Knobs.AP_HDG
+	case "crs":
+		return &s.profile.Knobs.AP_CRS
+	}
+	return nil
+}
+
+// pushPullKnob fires the push or pull commands of the selected knob.
+func (s *xplaneService) pushPullKnob(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
+	if phase != utilities.Phase_CommandBegin {
+		return 0
+	}
+	myProfile := s.selectedKnob()
+	if myProfile == nil {
+		return 0
+	}
+	commands := myProfile.Push
+	if ref.(string) == "pull" {
+		commands = myProfile.Pull
+	}
+	if len(commands) == 0 {
+		s.Logger.Debugf("No %s commands for knob: %s", ref.(string), s.apSelector)
+		return 0
+	}
+	for _, cmd := range commands {
+		s.queueCommand(knobQueuedCommand(cmd, 1))
+	}
+	return 0
+}
+
 func (s *xplaneService) adjust(myProfile pkg.KnobProfile, direction int, multiplier float64, step float64) {
-	if myProfile.Commands != nil {
-		var cmd utilities.CommandRef
-		if direction > 0 {
-			cmd = utilities.FindCommand(myProfile.Commands[0].CommandStr)
-		} else {
-			cmd = utilities.FindCommand(myProfile.Commands[1].CommandStr)
+	commands, repeat := knobTurnCommands(&myProfile.KnobCommands, direction, multiplier)
+	for _, cmd := range commands {
+		s.queueCommand(knobQueuedCommand(cmd, repeat))
+	}
+
+	step, limits := s.knobStepAndLimits(&myProfile, step)
+	for i := range myProfile.Datarefs {
+		myDataref := &myProfile.Datarefs[i]
+		handle := datarefOf(myDataref.Dataref)
+		if handle == nil {
+			handle = s.datarefs.lookup(myDataref.DatarefStr)
 		}
-		for i := 0; i < int(multiplier); i++ {
-			utilities.CommandOnce(cmd)
+		if handle == nil {
+			s.Logger.Errorf("Dataref[%d] not found: %s", i, myDataref.DatarefStr)
+			continue
 		}
-	}
 
-	for i := 0; i < len(myProfile.Datarefs); i++ {
-		myDatarefName := myProfile.Datarefs[i].DatarefStr
-		myDataref, found := dataAccess.FindDataRef(myDatarefName)
-		if !found {
-			s.Logger.Errorf("Dataref[%d] not found: %s", i, myDatarefName)
+		currentValue, err := handle.get(myDataref.Index)
+		if err != nil {
+			s.Logger.Errorf("%v", err)
 			continue
 		}
-		currentValueType := dataAccess.GetDataRefTypes(myDataref)
-		switch currentValueType {
-		case dataAccess.TypeFloat:
-			currentValue := dataAccess.GetFloatData(myDataref)
-			newValue := currentValue + float32(float64(direction)*multiplier*step)
-			s.Logger.Debugf("Knob dataref: %s, Current Value: %f, New Value: %f", myDatarefName, currentValue, newValue)
-			dataAccess.SetFloatData(myDataref, newValue)
-		case dataAccess.TypeInt:
-			currentValue := dataAccess.GetIntData(myDataref)
-			newValue := currentValue + int(float64(direction)*multiplier*step)
-			s.Logger.Debugf("Knob dataref: %s, Current Value: %f, New Value: %f", myDatarefName, currentValue, newValue)
-			dataAccess.SetIntData(myDataref, newValue)
+		newValue := knobValue(currentValue, float64(direction)*multiplier*step, limits)
+		s.Logger.Debugf("Knob dataref: %s[%d], Current Value: %f, New Value: %f", myDataref.DatarefStr, myDataref.Index, currentValue, newValue)
+		if err := handle.set(myDataref.Index, newValue); err != nil {
+			s.Logger.Errorf("%v", err)
 		}
 	}
 }
@@ -148,6 +183,8 @@ func (s *xplaneService) adjust(myProfile pkg.KnobProfile, direction int, multipl
 func (s *xplaneService) setupKnobsCmds() {
 	increaseCmd := utilities.CreateCommand("Honeycomb Bravo/increase", "Increase the value of the autopilot mode selected with the rotary encoder.")
 	decreaseCmd := utilities.CreateCommand("Honeycomb Bravo/decrease", "Decrease the value of the autopilot mode selected with the rotary encoder.")
+	pushCmd := utilities.CreateCommand("Honeycomb Bravo/push", "Push the knob of the autopilot mode selected with the rotary encoder.")
+	pullCmd := utilities.CreateCommand("Honeycomb Bravo/pull", "Pull the knob of the autopilot mode selected with the rotary encoder.")
 
 	mode_ias := utilities.CreateCommand("Honeycomb Bravo/mode_ias", "Set the autopilot mode to IAS.")
 	mode_alt := utilities.CreateCommand("Honeycomb Bravo/mode_alt", "Set the autopilot mode to ALT.")
@@ -158,6 +195,8 @@ func (s *xplaneService) setupKnobsCmds() {
 	// set up command handlers
 	utilities.RegisterCommandHandler(increaseCmd, s.changeApValue, true, "up")
 	utilities.RegisterCommandHandler(decreaseCmd, s.changeApValue, true, "down")
+	utilities.RegisterCommandHandler(pushCmd, s.pushPullKnob, true, "push")
+	utilities.RegisterCommandHandler(pullCmd, s.pushPullKnob, true, "pull")
 	utilities.RegisterCommandHandler(mode_ias, s.changeAPMode, true, "ias")
 	utilities.RegisterCommandHandler(mode_alt, s.changeAPMode, true, "alt")
 	utilities.RegisterCommandHandler(mode_vs, s.changeAPMode, true, "vs")
@@ -196,6 +235,184 @@ func (s *xplaneService) setupTrimCmds() {
 	utilities.RegisterCommandHandler(pitchTrimDown, s.trimPressed, true, "down")
 }
 
+func (s *xplaneService) setupSwitchCmds() {
+	for i := 1; i <= 7; i++ {
+		for _, position := range []string{"up", "down"} {
+			name := fmt.Sprintf("switch_%d_%s", i, position)
+			cmd := utilities.CreateCommand("Honeycomb Bravo/"+name, fmt.Sprintf("Bravo switch %d moved %s.", i, position))
+			utilities.RegisterCommandHandler(cmd, s.switchMoved, true, name)
+		}
+	}
+}
+
+func (s *xplaneService) switchMoved(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
+	// the switch stays "pressed" while it is in a position, so act once when it gets there
+	if phase == utilities.Phase_CommandBegin {
+		switchRef := ref.(string)
+		s.Logger.Debugf("Switch command: %v, Phase: %v, Switch: %s", command, phase, switchRef)
+
+		action := s.getSwitchAction(switchRef)
+		if action == nil {
+			s.Logger.Warningf("Unknown switch reference: %s", switchRef)
+			return 0
+		}
+		s.runAction(switchRef, action)
+	}
+	return 0
+}
+
+func (s *xplaneService) getSwitchAction(ref string) *pkg.ActionProfile {
+	if s.profile == nil || s.profile.Switches == nil {
+		return nil
+	}
+
+	name, position, found := strings.Cut(strings.TrimPrefix(ref, "switch_"), "_")
+	if !found {
+		return nil
+	}
+
+	var sw *pkg.SwitchProfile
+	switch name {
+	case "1":
+		sw = &s.profile.Switches.SWITCH_1
+	case "2":
+		sw = &s.profile.Switches.SWITCH_2
+	case "3":
+		sw = &s.profile.Switches.SWITCH_3
+	case "4":
+		sw = &s.profile.Switches.SWITCH_4
+	case "5":
+		sw = &s.profile.Switches.SWITCH_5
+	case "6":
+		sw = &s.profile.Switches.SWITCH_6
+	case "7":
+		sw = &s.profile.Switches.SWITCH_7
+	default:
+		return nil
+	}
+
+	switch position {
+	case "up":
+		return &sw.Up
+	case "down":
+		return &sw.Down
+	default:
+		return nil
+	}
+}
+
+func (s *xplaneService) setupGearAndFlapCmds() {
+	gearUp := utilities.CreateCommand("Honeycomb Bravo/gear_up", "Bravo gear lever moved up.")
+	gearDown := utilities.CreateCommand("Honeycomb Bravo/gear_down", "Bravo gear lever moved down.")
+	flapsUp := utilities.CreateCommand("Honeycomb Bravo/flaps_up", "Bravo flap lever moved up.")
+	flapsDown := utilities.CreateCommand("Honeycomb Bravo/flaps_down", "Bravo flap lever moved down.")
+
+	// set up command handlers
+	utilities.RegisterCommandHandler(gearUp, s.gearMoved, true, "up")
+	utilities.RegisterCommandHandler(gearDown, s.gearMoved, true, "down")
+	utilities.RegisterCommandHandler(flapsUp, s.flapsMoved, true, "up")
+	utilities.RegisterCommandHandler(flapsDown, s.flapsMoved, true, "down")
+}
+
+func (s *xplaneService) gearMoved(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
+	if phase == utilities.Phase_CommandBegin {
+		position := ref.(string)
+		s.Logger.Debugf("Gear command: %v, Phase: %v, Position: %s", command, phase, position)
+
+		action := s.gearLeverAction(position)
+		s.runAction("gear_lever."+position, &action)
+	}
+	return 0
+}
+
+// gearLeverAction returns the profile action for the gear lever position, or
+// the default X-Plane gear command if the profile has none.
+func (s *xplaneService) gearLeverAction(position string) pkg.ActionProfile {
+	var action pkg.ActionProfile
+	if s.profile != nil && s.profile.GearLever != nil {
+		if position == "up" {
+			action = s.profile.GearLever.Up
+		} else {
+			action = s.profile.GearLever.Down
+		}
+	}
+
+	if len(action.Commands) == 0 && len(action.Datarefs) == 0 {
+		cmd := defaultGearDownCommand
+		if position == "up" {
+			cmd = defaultGearUpCommand
+		}
+		action.Commands = []pkg.Command{{CommandStr: cmd}}
+	}
+	return action
+}
+
+func (s *xplaneService) flapsMoved(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
+	if phase == utilities.Phase_CommandBegin {
+		direction := ref.(string)
+		s.Logger.Debugf("Flaps command: %v, Phase: %v, Direction: %s", command, phase, direction)
+
+		if s.profile != nil && s.profile.FlapLever != nil && len(s.profile.FlapLever.Detents) > 0 {
+			s.stepFlapDetent(s.profile.FlapLever, direction == "up")
+			return 0
+		}
+
+		var action pkg.ActionProfile
+		if s.profile != nil && s.profile.FlapLever != nil {
+			if direction == "up" {
+				action = s.profile.FlapLever.Up
+			} else {
+				action = s.profile.FlapLever.Down
+			}
+		}
+		if len(action.Commands) == 0 && len(action.Datarefs) == 0 {
+			cmd := defaultFlapsDownCommand
+			if direction == "up" {
+				cmd = defaultFlapsUpCommand
+			}
+			action.Commands = []pkg.Command{{CommandStr: cmd}}
+		}
+		s.runAction("flap_lever."+direction, &action)
+	}
+	return 0
+}
+
+// stepFlapDetent moves the flap handle to the next detent. Moving the lever
+// up retracts the flaps, so it goes to the next lower ratio.
+func (s *xplaneService) stepFlapDetent(lever *pkg.FlapLeverProfile, up bool) {
+	current, ok := s.readDatarefValue(&lever.Dataref)
+	if !ok {
+		return
+	}
+
+	next, found := nextDetent(lever.Detents, float32(current), up)
+	if !found {
+		s.Logger.Debugf("Flaps already at the last detent: %f", current)
+		return
+	}
+	s.writeDatarefValue(&pkg.DatarefValue{Dataref: lever.Dataref, Value: next})
+}
+
+// nextDetent returns the detent after current in the given direction.
+// detents must be sorted in ascending order.
+func nextDetent(detents []float32, current float32, up bool) (float32, bool) {
+	if up {
+		for i := len(detents) - 1; i >= 0; i-- {
+			if detents[i] < current-flapDetentTolerance {
+				return detents[i], true
+			}
+		}
+		return 0, false
+	}
+
+	for _, detent := range detents {
+		if detent > current+flapDetentTolerance {
+			return detent, true
+		}
+	}
+	return 0, false
+}
+
 func (s *xplaneService) trimWheelConfig() (string, string, float64, int64) {
 	upCommand := defaultTrimUpCommand
 	downCommand := defaultTrimDownCommand
@@ -365,12 +582,38 @@ func (s *xplaneService) endTolissTrimCommandLocked(reason string) {
 }
 
 func (s *xplaneService) apPressed(command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int {
+	buttonRef := ref.(string) // Convert ref to string (or your button identifier type)
+	now := time.Now()
+	btn := s.getButtonProfile(buttonRef)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if phase == utilities.Phase_CommandBegin {
+		s.pressTimes[buttonRef] = now
+		if btn != nil && len(btn.Hold) > 0 {
+			s.beginHeldButton(buttonRef, btn.Hold)
+		}
+		return 0
+	}
+
 	if phase == utilities.Phase_CommandEnd {
-		buttonRef := ref.(string) // Convert ref to string (or your button identifier type)
-		now := time.Now()
+		held := time.Duration(0)
+		if pressed, ok := s.pressTimes[buttonRef]; ok {
+			held = now.Sub(pressed)
+			delete(s.pressTimes, buttonRef)
+		}
+		s.endHeldButton(buttonRef)
+		if btn != nil && len(btn.Hold) > 0 && !hasClickActions(btn) {
+			return 0
+		}
 
-		s.mutex.Lock()
-		defer s.mutex.Unlock()
+		doubleClickWindow, longPressThreshold := buttonTimingOf(s.profileButtonTiming())
+		if btn != nil && len(btn.LongPress) > 0 && held >= longPressThreshold {
+			s.Logger.Debugf("Long press detected for button: %s, held: %s", buttonRef, held)
+			s.handleClick(buttonRef, buttonGestureLongPress)
+			return 0
+		}
 
 		// Check if there's an existing timer for the button
 		if timer, exists := s.clickTimers[buttonRef]; exists {
@@ -378,12 +621,12 @@ func (s *xplaneService) apPressed(command utilities.CommandRef, phase utilities.
 			timer.Stop()
 			delete(s.clickTimers, buttonRef)
 			s.Logger.Debugf("Double-click detected for button: %s, timestamp: %s", buttonRef, now)
-			s.handleClick(buttonRef, true)
+			s.handleClick(buttonRef, buttonGestureDoubleClick)
 			return 0
 		}
 
 		// Single-click detected; set a timer to delay action
-		timer := time.AfterFunc(doubleClickThreshold, func() {
+		timer := time.AfterFunc(doubleClickWindow, func() {
 			s.mutex.Lock()
 			defer s.mutex.Unlock()
 
@@ -391,7 +634,7 @@ func (s *xplaneService) apPressed(command utilities.CommandRef, phase utilities.
 			if s.clickTimers[buttonRef] != nil {
 				delete(s.clickTimers, buttonRef)
 				s.Logger.Debugf("Single-click detected for button: %s, timestamp: %s", buttonRef, now)
-				s.handleClick(buttonRef, false)
+				s.handleClick(buttonRef, buttonGestureSingleClick)
 			}
 		})
 
@@ -403,59 +646,56 @@ func (s *xplaneService) apPressed(command utilities.CommandRef, phase utilities.
 	return 0
 }
 
-func (s *xplaneService) getButtonCommands(ref string, doubleClick bool) []pkg.Command {
+func (s *xplaneService) getButtonProfile(ref string) *pkg.ButtonProfile {
 	if s.profile == nil || s.profile.Buttons == nil {
 		return nil
 	}
 
-	var btn *pkg.ButtonProfile
 	switch ref {
 	case "hdg":
-		btn = &s.profile.Buttons.HDG
+		return &s.profile.Buttons.HDG
 	case "nav":
-		btn = &s.profile.Buttons.NAV
+		return &s.profile.Buttons.NAV
 	case "alt":
-		btn = &s.profile.Buttons.ALT
+		return &s.profile.Buttons.ALT
 	case "apr":
-		btn = &s.profile.Buttons.APR
+		return &s.profile.Buttons.APR
 	case "vs":
-		btn = &s.profile.Buttons.VS
+		return &s.profile.Buttons.VS
 	case "ap":
-		btn = &s.profile.Buttons.AP
+		return &s.profile.Buttons.AP
 	case "rev":
-		btn = &s.profile.Buttons.REV
+		return &s.profile.Buttons.REV
 	case "ias":
-		btn = &s.profile.Buttons.IAS
-	default:
-		// Unknown button ref
-		return nil
+		return &s.profile.Buttons.IAS
 	}
+	// Unknown button ref
+	return nil
+}
 
+func (s *xplaneService) getButtonActions(ref string, gesture string) []pkg.ButtonAction {
+	btn := s.getButtonProfile(ref)
 	if btn == nil {
 		return nil
 	}
 
-	if doubleClick {
+	switch gesture {
+	case buttonGestureDoubleClick:
 		return btn.DoubleClick
+	case buttonGestureLongPress:
+		return btn.LongPress
 	}
 	return btn.SingleClick
 }
 
-func (s *xplaneService) handleClick(ref string, doubleClick bool) {
+func (s *xplaneService) handleClick(ref string, gesture string) {
 	s.cmdEventQueueMu.Lock()
 	defer s.cmdEventQueueMu.Unlock()
 
-	cmds := s.getButtonCommands(ref, doubleClick)
-	if cmds != nil && len(cmds) > 0 {
-		for _, cmd := range cmds {
-			s.cmdEventQueue = append(s.cmdEventQueue, cmd.CommandStr)
-		}
+	actions := s.getButtonActions(ref, gesture)
+	if len(actions) > 0 {
+		s.actionQueue = append(s.actionQueue, actions)
 	} else {
-		// Differentiating log message based on single/double click
-		clickType := "Single-click"
-		if doubleClick {
-			clickType = "Double-click"
-		}
-		s.Logger.Warningf("%s detected for button: %s (no commands configured)", clickType, ref)
+		s.Logger.Warningf("%s detected for button: %s (no commands configured)", gesture, ref)
 	}
 }
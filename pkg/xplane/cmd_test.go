@@ -0,0 +1,48 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func TestNextDetent(t *testing.T) {
+	detents := []float32{0, 0.25, 0.5, 1}
+
+	tests := []struct {
+		name     string
+		current  float32
+		up       bool
+		expected float32
+		found    bool
+	}{
+		{"down from retracted", 0, false, 0.25, true},
+		{"down between detents", 0.3, false, 0.5, true},
+		{"down near a detent", 0.495, false, 1, true},
+		{"down at full flaps", 1, false, 0, false},
+		{"up from full flaps", 1, true, 0.5, true},
+		{"up between detents", 0.3, true, 0.25, true},
+		{"up when retracted", 0, true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, found := nextDetent(detents, tt.current, tt.up)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, next)
+		})
+	}
+}
+
+func TestGearLeverActionFallsBackToDefaultCommands(t *testing.T) {
+	s := &xplaneService{profile: &pkg.Profile{}}
+
+	assert.Equal(t, []pkg.Command{{CommandStr: defaultGearUpCommand}}, s.gearLeverAction("up").Commands)
+	assert.Equal(t, []pkg.Command{{CommandStr: defaultGearDownCommand}}, s.gearLeverAction("down").Commands)
+
+	s.profile.GearLever = &pkg.GearLeverProfile{
+		Down: pkg.ActionProfile{Commands: []pkg.Command{{CommandStr: "AirbusFBW/GearDown"}}},
+	}
+	assert.Equal(t, []pkg.Command{{CommandStr: "AirbusFBW/GearDown"}}, s.gearLeverAction("down").Commands)
+	assert.Equal(t, []pkg.Command{{CommandStr: defaultGearUpCommand}}, s.gearLeverAction("up").Commands)
+}
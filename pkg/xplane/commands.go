@@ -0,0 +1,150 @@
+package xplane
+
+import (
+	"fmt"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/xairline/goplane/xplm/utilities"
+)
+
+const defaultCommandHold = 0.2
+
+// queuedCommand is a command press waiting for the flight loop: the command
+// is begun, held for hold seconds and ended, repeat times. A hold of 0 is a
+// tap, begun and ended in the same flight loop.
+type queuedCommand struct {
+	cmdStr string
+	hold   float64
+	repeat int
+}
+
+// commandState tracks one command. Presses of a command that is still held
+// wait in pending, so every press gets its own begin and end.
+type commandState struct {
+	startTime float64
+	hold      float64
+	active    bool
+	pending   []float64
+}
+
+func queuedCommandOf(cmd pkg.Command) queuedCommand {
+	hold := defaultCommandHold
+	if cmd.Hold > 0 {
+		hold = float64(cmd.Hold)
+	}
+	return queuedCommand{cmdStr: cmd.CommandStr, hold: hold, repeat: cmd.Repeat}
+}
+
+// knobQueuedCommand returns the presses of a knob command for presses knob
+// steps. Knob commands are tapped unless they set a hold, so fast turns are
+// not slowed down by the flight loop.
+func knobQueuedCommand(cmd pkg.Command, presses int) queuedCommand {
+	return queuedCommand{cmdStr: cmd.CommandStr, hold: float64(cmd.Hold), repeat: presses * max(cmd.Repeat, 1)}
+}
+
+func validateQueuedCommand(cmd pkg.Command) error {
+	if cmd.Hold < 0 {
+		return fmt.Errorf("hold must not be negative, got %v", cmd.Hold)
+	}
+	if cmd.Repeat < 0 {
+		return fmt.Errorf("repeat must not be negative, got %d", cmd.Repeat)
+	}
+	return nil
+}
+
+// queue adds the presses of a queued command.
+func (c *commandState) queue(queued queuedCommand) {
+	for i := 0; i < max(queued.repeat, 1); i++ {
+		c.pending = append(c.pending, queued.hold)
+	}
+}
+
+// takeTaps removes the taps at the front of the queue and returns how many
+// there were. Taps wait while a press is held.
+func (c *commandState) takeTaps() int {
+	if c.active {
+		return 0
+	}
+	taps := 0
+	for taps < len(c.pending) && c.pending[taps] <= 0 {
+		taps++
+	}
+	c.pending = c.pending[taps:]
+	return taps
+}
+
+// step moves the command forward at now and tells if it has to be begun or
+// ended. A press that ends is not followed by the next one in the same step,
+// so X-Plane sees every release.
+func (c *commandState) step(now float64) (begin bool, end bool) {
+	if c.active {
+		if now-c.startTime >= c.hold {
+			c.active = false
+			return false, true
+		}
+		return false, false
+	}
+	if len(c.pending) == 0 {
+		return false, false
+	}
+	c.hold = c.pending[0]
+	c.pending = c.pending[1:]
+	c.startTime = now
+	c.active = true
+	return true, false
+}
+
+func (c *commandState) done() bool {
+	return !c.active && len(c.pending) == 0
+}
+
+// queueCommand schedules the presses of a command. They start in the next
+// updateCommands.
+func (s *xplaneService) queueCommand(queued queuedCommand) {
+	if utilities.FindCommand(queued.cmdStr) == nil {
+		s.Logger.Errorf("Command not found: %s", queued.cmdStr)
+		return
+	}
+
+	state, exists := s.commandStates[queued.cmdStr]
+	if !exists {
+		state = &commandState{}
+		s.commandStates[queued.cmdStr] = state
+	} else {
+		s.Logger.Debugf("Command %s is already active, queueing the press.", queued.cmdStr)
+	}
+	state.queue(queued)
+}
+
+// updateCommands begins and ends the queued commands. It is called from the
+// flight loop.
+func (s *xplaneService) updateCommands() {
+	for cmdStr, state := range s.commandStates {
+		taps := state.takeTaps()
+		begin, end := state.step(s.globalTime)
+		if taps > 0 || begin || end {
+			cmd := utilities.FindCommand(cmdStr)
+			if cmd == nil {
+				s.Logger.Errorf("Command not found: %s", cmdStr)
+				delete(s.commandStates, cmdStr)
+				continue
+			}
+			if taps > 0 {
+				s.Logger.Debugf("Tapping command %d times: %s", taps, cmdStr)
+				for i := 0; i < taps; i++ {
+					utilities.CommandOnce(cmd)
+				}
+			}
+			if begin {
+				s.Logger.Debugf("Beginning command: %s", cmdStr)
+				utilities.CommandBegin(cmd)
+			} else if end {
+				s.Logger.Debugf("Ending command: %s", cmdStr)
+				utilities.CommandEnd(cmd)
+			}
+		}
+		if state.done() {
+			delete(s.commandStates, cmdStr)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func TestQueuedCommandOf(t *testing.T) {
+	assert.Equal(t, queuedCommand{cmdStr: "a", hold: defaultCommandHold}, queuedCommandOf(pkg.Command{CommandStr: "a"}))
+	assert.Equal(t, queuedCommand{cmdStr: "a", hold: 1.5, repeat: 3}, queuedCommandOf(pkg.Command{CommandStr: "a", Hold: 1.5, Repeat: 3}))
+
+	assert.NoError(t, validateQueuedCommand(pkg.Command{CommandStr: "a", Hold: 1, Repeat: 2}))
+	assert.Error(t, validateQueuedCommand(pkg.Command{CommandStr: "a", Hold: -1}))
+	assert.Error(t, validateQueuedCommand(pkg.Command{CommandStr: "a", Repeat: -1}))
+}
+
+func TestCommandStateRepeats(t *testing.T) {
+	state := &commandState{}
+	state.queue(queuedCommand{cmdStr: "a", hold: 0.5, repeat: 2})
+
+	type step struct {
+		now        float64
+		begin, end bool
+	}
+	steps := []step{
+		{1.0, true, false},
+		{1.25, false, false},
+		{1.5, false, true},
+		{1.75, true, false},
+		{2.25, false, true},
+	}
+	for _, s := range steps {
+		begin, end := state.step(s.now)
+		assert.Equal(t, s.begin, begin, "begin at %v", s.now)
+		assert.Equal(t, s.end, end, "end at %v", s.now)
+	}
+	assert.True(t, state.done())
+}
+
+func TestCommandStateQueuesReentrantPresses(t *testing.T) {
+	state := &commandState{}
+	state.queue(queuedCommand{cmdStr: "a", hold: 0.2})
+
+	begin, _ := state.step(1.0)
+	assert.True(t, begin)
+
+	// pressed again while held: the second press waits for the first to end
+	state.queue(queuedCommand{cmdStr: "a", hold: 1})
+	begin, end := state.step(1.1)
+	assert.False(t, begin)
+	assert.False(t, end)
+
+	_, end = state.step(1.25)
+	assert.True(t, end)
+	assert.False(t, state.done())
+
+	begin, _ = state.step(1.5)
+	assert.True(t, begin)
+	_, end = state.step(2.0)
+	assert.False(t, end, "second press keeps its own hold")
+	_, end = state.step(2.5)
+	assert.True(t, end)
+	assert.True(t, state.done())
+}
+
+func TestKnobQueuedCommand(t *testing.T) {
+	assert.Equal(t, queuedCommand{cmdStr: "a", repeat: 5}, knobQueuedCommand(pkg.Command{CommandStr: "a"}, 5))
+	assert.Equal(t, queuedCommand{cmdStr: "a", hold: 0.5, repeat: 6}, knobQueuedCommand(pkg.Command{CommandStr: "a", Hold: 0.5, Repeat: 3}, 2))
+}
+
+func TestCommandStateTapsWaitForHeldPress(t *testing.T) {
+	state := &commandState{}
+	state.queue(queuedCommand{cmdStr: "a", repeat: 2})
+	state.queue(queuedCommand{cmdStr: "a", hold: 0.5})
+	state.queue(queuedCommand{cmdStr: "a"})
+
+	assert.Equal(t, 2, state.takeTaps())
+	begin, _ := state.step(1.0)
+	assert.True(t, begin)
+
+	assert.Equal(t, 0, state.takeTaps(), "taps wait while a press is held")
+	_, end := state.step(1.5)
+	assert.True(t, end)
+
+	assert.Equal(t, 1, state.takeTaps())
+	assert.True(t, state.done())
+}
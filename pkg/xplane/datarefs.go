@@ -0,0 +1,156 @@
+package xplane
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xairline/goplane/xplm/dataAccess"
+)
+
+// datarefHandle is a dataref resolved by the registry. Its type is looked up
+// once, so reads and writes don't have to ask X-Plane every time.
+type datarefHandle struct {
+	name string
+	ref  dataAccess.DataRef
+	kind dataAccess.DataRefType
+}
+
+// datarefRegistry resolves datarefs by name and keeps the handles until the
+// next profile load. Datarefs that are not found are looked up again the next
+// time, since plugins may create them late. The zero value is ready to use.
+type datarefRegistry struct {
+	find    func(name string) (dataAccess.DataRef, dataAccess.DataRefType, bool)
+	handles map[string]*datarefHandle
+}
+
+func findDataref(name string) (dataAccess.DataRef, dataAccess.DataRefType, bool) {
+	ref, found := dataAccess.FindDataRef(name)
+	if !found {
+		return nil, dataAccess.TypeUnknown, false
+	}
+	return ref, dataAccess.GetDataRefTypes(ref), true
+}
+
+// lookup returns the handle for name, or nil if X-Plane has no such dataref.
+func (r *datarefRegistry) lookup(name string) *datarefHandle {
+	if handle, ok := r.handles[name]; ok {
+		return handle
+	}
+
+	find := r.find
+	if find == nil {
+		find = findDataref
+	}
+	ref, kind, found := find(name)
+	if !found {
+		return nil
+	}
+
+	if r.handles == nil {
+		r.handles = map[string]*datarefHandle{}
+	}
+	handle := &datarefHandle{name: name, ref: ref, kind: kind}
+	r.handles[name] = handle
+	return handle
+}
+
+// reset forgets every handle, e.g. when another aircraft is loaded.
+func (r *datarefRegistry) reset() {
+	r.handles = nil
+}
+
+// datarefOf returns the handle stored in a profile's Dataref field, or nil if
+// it was not resolved.
+func datarefOf(dataref interface{}) *datarefHandle {
+	handle, _ := dataref.(*datarefHandle)
+	return handle
+}
+
+func (h *datarefHandle) isArray() bool {
+	return h.kind&(dataAccess.TypeFloatArray|dataAccess.TypeIntArray) > 0
+}
+
+// get returns the value of the dataref, or of the element at index for array
+// datarefs. String datarefs are read as a number.
+func (h *datarefHandle) get(index int) (float64, error) {
+	values, err := h.values()
+	if err != nil {
+		return 0.0, err
+	}
+	if !h.isArray() {
+		return values[0], nil
+	}
+	if index < 0 || index >= len(values) {
+		return 0.0, fmt.Errorf("Index %d out of range for dataref: %s", index, h.name)
+	}
+	return values[index], nil
+}
+
+// values returns every element of an array dataref, or the single value of
+// any other dataref.
+func (h *datarefHandle) values() ([]float64, error) {
+	if h.kind&dataAccess.TypeFloat > 0 {
+		return []float64{float64(dataAccess.GetFloatData(h.ref))}, nil
+	} else if h.kind&dataAccess.TypeInt > 0 {
+		return []float64{float64(dataAccess.GetIntData(h.ref))}, nil
+	} else if h.kind&dataAccess.TypeDouble > 0 {
+		return []float64{dataAccess.GetDoubleData(h.ref)}, nil
+	} else if h.kind&dataAccess.TypeFloatArray > 0 {
+		values := dataAccess.GetFloatArrayData(h.ref)
+		res := make([]float64, len(values))
+		for i, v := range values {
+			res[i] = float64(v)
+		}
+		return res, nil
+	} else if h.kind&dataAccess.TypeIntArray > 0 {
+		values := dataAccess.GetIntArrayData(h.ref)
+		res := make([]float64, len(values))
+		for i, v := range values {
+			res[i] = float64(v)
+		}
+		return res, nil
+	} else if h.kind&dataAccess.TypeData > 0 {
+		text := strings.TrimSpace(h.text())
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Dataref %s is not a number: %q", h.name, text)
+		}
+		return []float64{value}, nil
+	}
+	return nil, fmt.Errorf("Dataref type not supported: %v", h.kind)
+}
+
+// text returns the value of a string dataref.
+func (h *datarefHandle) text() string {
+	return dataAccess.GetString(h.ref)
+}
+
+// set stores value into the dataref, or into the element at index for array
+// datarefs.
+func (h *datarefHandle) set(index int, value float64) error {
+	if h.kind&dataAccess.TypeFloat > 0 {
+		dataAccess.SetFloatData(h.ref, float32(value))
+	} else if h.kind&dataAccess.TypeInt > 0 {
+		dataAccess.SetIntData(h.ref, int(value))
+	} else if h.kind&dataAccess.TypeDouble > 0 {
+		dataAccess.SetDoubleData(h.ref, value)
+	} else if h.kind&dataAccess.TypeFloatArray > 0 {
+		values := dataAccess.GetFloatArrayData(h.ref)
+		if index < 0 || index >= len(values) {
+			return fmt.Errorf("Index %d out of range for dataref: %s", index, h.name)
+		}
+		values[index] = float32(value)
+		dataAccess.SetFloatArrayData(h.ref, values)
+	} else if h.kind&dataAccess.TypeIntArray > 0 {
+		values := dataAccess.GetIntArrayData(h.ref)
+		if index < 0 || index >= len(values) {
+			return fmt.Errorf("Index %d out of range for dataref: %s", index, h.name)
+		}
+		values[index] = int(value)
+		dataAccess.SetIntArrayData(h.ref, values)
+	} else {
+		return fmt.Errorf("Dataref type not supported: %v", h.kind)
+	}
+	return nil
+}
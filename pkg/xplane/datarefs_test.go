@@ -0,0 +1,47 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xairline/goplane/xplm/dataAccess"
+)
+
+func TestDatarefRegistryResolvesEachNameOnce(t *testing.T) {
+	lookups := map[string]int{}
+	registry := datarefRegistry{
+		find: func(name string) (dataAccess.DataRef, dataAccess.DataRefType, bool) {
+			lookups[name]++
+			if name == "missing" {
+				return nil, dataAccess.TypeUnknown, false
+			}
+			return nil, dataAccess.TypeFloatArray, true
+		},
+	}
+
+	first := registry.lookup("sim/cockpit2/engine/actuators/throttle_ratio")
+	second := registry.lookup("sim/cockpit2/engine/actuators/throttle_ratio")
+	assert.NotNil(t, first)
+	assert.Same(t, first, second)
+	assert.True(t, first.isArray())
+	assert.Equal(t, 1, lookups["sim/cockpit2/engine/actuators/throttle_ratio"])
+
+	// missing datarefs are looked up again, they may be created later
+	assert.Nil(t, registry.lookup("missing"))
+	assert.Nil(t, registry.lookup("missing"))
+	assert.Equal(t, 2, lookups["missing"])
+
+	registry.reset()
+	assert.NotSame(t, first, registry.lookup("sim/cockpit2/engine/actuators/throttle_ratio"))
+	assert.Equal(t, 2, lookups["sim/cockpit2/engine/actuators/throttle_ratio"])
+}
+
+func TestDatarefOf(t *testing.T) {
+	var missing *datarefHandle
+	assert.Nil(t, datarefOf(nil))
+	assert.Nil(t, datarefOf(missing))
+	assert.Nil(t, datarefOf("sim/not/a/handle"))
+
+	handle := &datarefHandle{name: "sim/cockpit2/autopilot/heading_dial_deg_mag_pilot"}
+	assert.Same(t, handle, datarefOf(interface{}(handle)))
+}
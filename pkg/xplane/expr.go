@@ -0,0 +1,94 @@
+package xplane
+
+import (
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/xairline/goplane/xplm/dataAccess"
+)
+
+// compileConditionExpr compiles a condition expression against env, which
+// holds a value of the right type for every variable. Builtins are disabled
+// and env only holds plain values, so an expression can do arithmetic,
+// comparisons and boolean logic on the variables and nothing else.
+func compileConditionExpr(code string, env map[string]interface{}) (*vm.Program, error) {
+	return expr.Compile(code, expr.Env(env), expr.AsBool(), expr.DisableAllBuiltins())
+}
+
+// exprValueOf returns the zero value an expression variable for a dataref of
+// the given type holds: float64 for scalars, []float64 for arrays and string
+// for byte arrays.
+func exprValueOf(datarefType dataAccess.DataRefType) (interface{}, bool) {
+	if datarefType&(dataAccess.TypeFloat|dataAccess.TypeInt|dataAccess.TypeDouble) > 0 {
+		return float64(0), true
+	}
+	if datarefType&(dataAccess.TypeFloatArray|dataAccess.TypeIntArray) > 0 {
+		return []float64{}, true
+	}
+	if datarefType&dataAccess.TypeData > 0 {
+		return "", true
+	}
+	return nil, false
+}
+
+// loadConditionExpr resolves the variables of an expression condition and
+// compiles it.
+func (s *xplaneService) loadConditionExpr(fieldName string, fieldValue *pkg.ConditionProfile) error {
+	if len(fieldValue.Datarefs) > 0 || len(fieldValue.Groups) > 0 {
+		return fmt.Errorf("Condition has expr together with datarefs or groups: %s", fieldName)
+	}
+
+	refs := map[string]interface{}{}
+	env := map[string]interface{}{}
+	for name, datarefStr := range fieldValue.Vars {
+		myDataref := s.getDataref(datarefStr)
+		if myDataref == nil {
+			return fmt.Errorf("Dataref not found for %s: %s", name, datarefStr)
+		}
+		value, ok := exprValueOf(myDataref.kind)
+		if !ok {
+			return fmt.Errorf("Dataref type not supported: %v", myDataref.kind)
+		}
+		refs[name] = myDataref
+		env[name] = value
+	}
+
+	s.Logger.Infof("---- Compiling expression: %s - %s", fieldValue.Expr, fieldName)
+	program, err := compileConditionExpr(fieldValue.Expr, env)
+	if err != nil {
+		return fmt.Errorf("Error compiling expression: %v", err)
+	}
+	fieldValue.Program = program
+	fieldValue.Refs = refs
+	fieldValue.Env = env
+	return nil
+}
+
+// runConditionExpr reads the variables of an expression condition and runs it.
+func (s *xplaneService) runConditionExpr(condition *pkg.ConditionProfile) (bool, error) {
+	for name, ref := range condition.Refs {
+		handle := ref.(*datarefHandle)
+		if _, ok := condition.Env[name].(string); ok {
+			condition.Env[name] = handle.text()
+			continue
+		}
+
+		values, err := handle.values()
+		if err != nil {
+			return false, err
+		}
+		if handle.isArray() {
+			condition.Env[name] = values
+		} else {
+			condition.Env[name] = values[0]
+		}
+	}
+
+	output, err := expr.Run(condition.Program, condition.Env)
+	if err != nil {
+		return false, err
+	}
+	return output.(bool), nil
+}
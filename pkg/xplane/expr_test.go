@@ -0,0 +1,82 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/expr-lang/expr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func TestCompileConditionExpr(t *testing.T) {
+	env := map[string]interface{}{
+		"n1":          []float64{},
+		"oil_p":       []float64{},
+		"test_switch": float64(0),
+	}
+	program, err := compileConditionExpr("(n1[0] > 20 && oil_p[0] < 25) || test_switch == 1", env)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		n1, oilP   float64
+		testSwitch float64
+		want       bool
+	}{
+		{"engine running with low oil pressure", 60, 10, 0, true},
+		{"engine running with good oil pressure", 60, 40, 0, false},
+		{"engine off", 0, 0, 0, false},
+		{"test switch", 0, 0, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := expr.Run(program, map[string]interface{}{
+				"n1":          []float64{tt.n1, tt.n1},
+				"oil_p":       []float64{tt.oilP, tt.oilP},
+				"test_switch": tt.testSwitch,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, output)
+		})
+	}
+}
+
+func TestCompileConditionExprRejectsUnsafeExpressions(t *testing.T) {
+	env := map[string]interface{}{
+		"volts": float64(0),
+	}
+	for _, code := range []string{
+		"volts + 1",          // not a boolean
+		"amps > 1",           // unknown variable
+		"max(volts, 1) > 1",  // builtins are disabled
+		"len(\"abc\") > 1",   // builtins are disabled
+		"volts > 1 && now()", // no functions in the environment
+	} {
+		_, err := compileConditionExpr(code, env)
+		assert.Error(t, err, code)
+	}
+}
+
+func TestFailingConditionExprIsLoggedOnce(t *testing.T) {
+	env := map[string]interface{}{"n1": []float64{}}
+	program, err := compileConditionExpr("n1[3] > 20", env)
+	assert.NoError(t, err)
+	mockLogger := new(MockLogger)
+	mockLogger.On("Errorf", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Infof", mock.Anything, mock.Anything).Return()
+	s := &xplaneService{Logger: mockLogger}
+	condition := &pkg.ConditionProfile{Expr: "n1[3] > 20", Program: program, Env: env}
+
+	for i := 0; i < 3; i++ {
+		result, valid := s.evaluateConditionRules(condition)
+		assert.False(t, result)
+		assert.True(t, valid)
+	}
+	mockLogger.AssertNumberOfCalls(t, "Errorf", 1)
+
+	// a reload logs it again
+	reloaded := &pkg.ConditionProfile{ExprFailed: true}
+	assert.NoError(t, s.loadConditionProfile("leds.engine_fire", reloaded))
+	assert.False(t, reloaded.ExprFailed)
+}
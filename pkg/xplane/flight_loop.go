@@ -5,8 +5,11 @@ import (
 
 	"github.com/x-z7a/zoal-honeycomb/pkg"
 	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
-	"github.com/xairline/goplane/xplm/dataAccess"
-	"github.com/xairline/goplane/xplm/utilities"
+)
+
+const (
+	defaultGearDownThreshold = 0.99
+	defaultGearUpThreshold   = 0.01
 )
 
 // flightLoop is called periodically. You return 0.1, meaning it runs every ~100ms
@@ -17,10 +20,13 @@ func (s *xplaneService) flightLoop(
 	ref interface{},
 ) float32 {
 
-	if honeycomb.BRAVO_CONNECTED == false {
-		return 0
+	if !honeycomb.BRAVO_CONNECTED.Load() {
+		// check again in a second, the bravo service reconnects in the background
+		return 1
 	}
 
+	s.processInput()
+
 	if s.profile == nil {
 		s.Logger.Info("Profile is nil, try to load it again")
 		s.lastCounter = 0
@@ -36,54 +42,30 @@ func (s *xplaneService) flightLoop(
 	s.globalTime += float64(elapsedSinceLastCall)
 
 	if counter-s.lastCounter > 200 {
-		honeycomb.LED_STATE_CHANGED_LOCK.Lock()
-		honeycomb.LED_STATE_CHANGED = true
-		honeycomb.LED_STATE_CHANGED_LOCK.Unlock()
+		s.BravoService.Panel().Invalidate()
 		s.lastCounter = counter
 	}
 
 	s.updateLeds()
+	s.updateLevers()
 
 	s.cmdEventQueueMu.Lock()
 	queuedCommands := s.cmdEventQueue
-	s.cmdEventQueue = []string{}
+	s.cmdEventQueue = nil
+	queuedActions := s.actionQueue
+	s.actionQueue = nil
 	s.cmdEventQueueMu.Unlock()
 
 	// Process new command events:
-	for _, cmdStr := range queuedCommands {
-		cmd := utilities.FindCommand(cmdStr)
-		if cmd == nil {
-			s.Logger.Errorf("Command not found: %s", cmdStr)
-			continue
-		}
-
-		// Start the command if it's not already active
-		if _, exists := s.commandStates[cmdStr]; !exists {
-			s.Logger.Debugf("Beginning command: %s", cmdStr)
-			utilities.CommandBegin(cmd)
-			s.commandStates[cmdStr] = &commandState{
-				startTime: s.globalTime,
-				active:    true,
-			}
-		} else {
-			// If this command is already active, either handle it differently
-			// or log a message. Typically you'd want one begin/end cycle at a time.
-			s.commandStates[cmdStr].startTime = -9999
-			s.Logger.Warningf("Command %s is already active.", cmdStr)
-		}
+	for _, queued := range queuedCommands {
+		s.queueCommand(queued)
 	}
-
-	// End commands that have been held for at least 200ms
-	for cmdStr, state := range s.commandStates {
-		if state.active && (s.globalTime-state.startTime) >= 0.2 {
-			cmd := utilities.FindCommand(cmdStr)
-			if cmd != nil {
-				s.Logger.Debugf("Ending command: %s", cmdStr)
-				utilities.CommandEnd(cmd)
-			}
-			delete(s.commandStates, cmdStr)
-		}
+	for _, actions := range queuedActions {
+		s.runButtonActions(actions)
 	}
+	s.updateMacros()
+
+	s.updateCommands()
 
 	s.endTolissTrimCommandIfIdle()
 
@@ -93,16 +75,13 @@ func (s *xplaneService) flightLoop(
 
 func (s *xplaneService) updateLeds() {
 	if s.profile == nil {
-		honeycomb.PROFILE_LOADED = false
 		return
 	}
 
-	honeycomb.PROFILE_LOADED = true
-
 	// special case for bus voltage
 	busVoltage, busVoltageOK := s.evaluateCondition(&s.profile.Conditions.BUS_VOLTAGE)
 	if busVoltageOK && !busVoltage {
-		honeycomb.AllOff()
+		s.allLedsOff()
 		return
 	}
 
@@ -111,6 +90,11 @@ func (s *xplaneService) updateLeds() {
 	for i := 0; i < val.NumField(); i++ {
 		field := typ.Field(i) // Get the field metadata
 		fieldName := field.Name
+		if fieldName == "GEAR" {
+			// special case for gear
+			s.updateGearLEDs(&s.profile.Leds.GEAR)
+			continue
+		}
 		// Get the field value as a reflect.Value
 		fieldVal := val.Field(i)
 		// Perform type assertion to BravoProfile
@@ -120,23 +104,7 @@ func (s *xplaneService) updateLeds() {
 			continue
 		}
 
-		if fieldValue.Datarefs == nil {
-			continue
-		}
-
-		if fieldName == "GEAR" {
-			// special case for gear
-			retractableGear, retractableGearOK := s.evaluateCondition(&s.profile.Conditions.RETRACTABLE_GEAR)
-			if retractableGearOK && !retractableGear {
-				s.updateGearLEDs([]float32{0, 0, 0})
-				continue
-			}
-
-			dataref := s.profile.Leds.GEAR.Datarefs[0]
-			if dataref.Dataref != nil {
-				output := dataAccess.GetFloatArrayData(dataref.Dataref.(dataAccess.DataRef))
-				s.updateGearLEDs(output)
-			}
+		if fieldValue.Datarefs == nil && fieldValue.Groups == nil && fieldValue.Program == nil {
 			continue
 		}
 
@@ -151,61 +119,60 @@ func (s *xplaneService) updateLeds() {
 			s.Logger.Errorf("Condition not OK for LED: %s", fieldName)
 		}
 	}
+
+	s.applyLeds()
 }
 
-func (s *xplaneService) updateGearLEDs(output []float32) {
-	if output[0] >= 0.99 {
-		honeycomb.OnLEDNoseGearGreen()
-		honeycomb.OffLEDNoseGearRed()
-	}
-	if output[0] <= 0.01 {
-		honeycomb.OffLEDNoseGearGreen()
-		honeycomb.OffLEDNoseGearRed()
-	}
-	if output[0] > 0.01 && output[0] < 0.99 {
-		honeycomb.OffLEDNoseGearGreen()
-		honeycomb.OnLEDNoseGearRed()
+// updateGearLEDs sets the green and red LED of every gear leg from its
+// deploy ratio.
+func (s *xplaneService) updateGearLEDs(gear *pkg.GearLEDProfile) {
+	retractableGear, retractableGearOK := s.evaluateCondition(&s.profile.Conditions.RETRACTABLE_GEAR)
+	if retractableGearOK && !retractableGear {
+		s.setGearLeds(false, false)
+		return
 	}
 
-	if output[1] >= 0.99 {
-		honeycomb.OnLEDLeftGearGreen()
-		honeycomb.OffLEDLeftGearRed()
+	downThreshold := float32(defaultGearDownThreshold)
+	if gear.DownThreshold != nil {
+		downThreshold = *gear.DownThreshold
 	}
-	if output[1] <= 0.01 {
-		honeycomb.OffLEDLeftGearGreen()
-		honeycomb.OffLEDLeftGearRed()
+	upThreshold := float32(defaultGearUpThreshold)
+	if gear.UpThreshold != nil {
+		upThreshold = *gear.UpThreshold
 	}
-	if output[1] > 0.01 && output[1] < 0.99 {
-		honeycomb.OffLEDLeftGearGreen()
-		honeycomb.OnLEDLeftGearRed()
+	unsafe, unsafeOK := s.evaluateCondition(&gear.Unsafe)
+	unsafe = unsafe && unsafeOK
+
+	legs := []struct {
+		dataref    *pkg.Dataref
+		green, red honeycomb.LED
+	}{
+		{gear.Nose, honeycomb.LED_NOSE_GEAR_GREEN, honeycomb.LED_NOSE_GEAR_RED},
+		{gear.Left, honeycomb.LED_LEFT_GEAR_GREEN, honeycomb.LED_LEFT_GEAR_RED},
+		{gear.Right, honeycomb.LED_RIGHT_GEAR_GREEN, honeycomb.LED_RIGHT_GEAR_RED},
 	}
-
-	if s.profile.Metadata.Name != "Flight Factor B772" && s.profile.Metadata.Name != "Flight Factor B777-F Freighter" {
-		if output[2] >= 0.99 {
-			honeycomb.OnLEDRightGearGreen()
-			honeycomb.OffLEDRightGearRed()
-		}
-		if output[2] <= 0.01 {
-			honeycomb.OffLEDRightGearGreen()
-			honeycomb.OffLEDRightGearRed()
-		}
-		if output[2] > 0.01 && output[2] < 0.99 {
-			honeycomb.OffLEDRightGearGreen()
-			honeycomb.OnLEDRightGearRed()
-		}
-	} else {
-		if output[3] >= 0.99 {
-			honeycomb.OnLEDRightGearGreen()
-			honeycomb.OffLEDRightGearRed()
-		}
-		if output[3] <= 0.01 {
-			honeycomb.OffLEDRightGearGreen()
-			honeycomb.OffLEDRightGearRed()
+	for _, leg := range legs {
+		if leg.dataref == nil || datarefOf(leg.dataref.Dataref) == nil {
+			continue
 		}
-		if output[3] > 0.01 && output[3] < 0.99 {
-			honeycomb.OffLEDRightGearGreen()
-			honeycomb.OnLEDRightGearRed()
+		ratio, ok := s.readDatarefValue(leg.dataref)
+		if !ok {
+			continue
 		}
+		green, red := gearLegLeds(float32(ratio), downThreshold, upThreshold, unsafe)
+		s.leds.Set(leg.green, green)
+		s.leds.Set(leg.red, red)
 	}
+}
 
+// gearLegLeds returns the green and red LED state for one gear leg.
+func gearLegLeds(ratio, downThreshold, upThreshold float32, unsafe bool) (bool, bool) {
+	if ratio >= downThreshold {
+		return true, false
+	}
+	if ratio <= upThreshold {
+		return false, unsafe
+	}
+	// in transit
+	return false, true
 }
@@ -0,0 +1,97 @@
+package xplane
+
+import (
+	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
+	"github.com/xairline/goplane/xplm/utilities"
+)
+
+type commandHandler func(s *xplaneService, command utilities.CommandRef, phase utilities.CommandPhase, ref interface{}) int
+
+// inputBinding routes a Bravo button to the handler of the matching
+// "Honeycomb Bravo/*" command, so reading the device directly behaves the
+// same as binding the button to that command in X-Plane.
+type inputBinding struct {
+	handler commandHandler
+	ref     string
+}
+
+var inputBindings = map[honeycomb.Button]inputBinding{
+	honeycomb.BUTTON_HDG:           {(*xplaneService).apPressed, "hdg"},
+	honeycomb.BUTTON_NAV:           {(*xplaneService).apPressed, "nav"},
+	honeycomb.BUTTON_APR:           {(*xplaneService).apPressed, "apr"},
+	honeycomb.BUTTON_REV:           {(*xplaneService).apPressed, "rev"},
+	honeycomb.BUTTON_ALT:           {(*xplaneService).apPressed, "alt"},
+	honeycomb.BUTTON_VS:            {(*xplaneService).apPressed, "vs"},
+	honeycomb.BUTTON_IAS:           {(*xplaneService).apPressed, "ias"},
+	honeycomb.BUTTON_AP:            {(*xplaneService).apPressed, "ap"},
+	honeycomb.BUTTON_ENCODER_INC:   {(*xplaneService).changeApValue, "up"},
+	honeycomb.BUTTON_ENCODER_DEC:   {(*xplaneService).changeApValue, "down"},
+	honeycomb.BUTTON_SELECTOR_ALT:  {(*xplaneService).changeAPMode, "alt"},
+	honeycomb.BUTTON_SELECTOR_VS:   {(*xplaneService).changeAPMode, "vs"},
+	honeycomb.BUTTON_SELECTOR_HDG:  {(*xplaneService).changeAPMode, "hdg"},
+	honeycomb.BUTTON_SELECTOR_CRS:  {(*xplaneService).changeAPMode, "crs"},
+	honeycomb.BUTTON_SELECTOR_IAS:  {(*xplaneService).changeAPMode, "ias"},
+	honeycomb.BUTTON_TRIM_UP:       {(*xplaneService).trimPressed, "up"},
+	honeycomb.BUTTON_TRIM_DOWN:     {(*xplaneService).trimPressed, "down"},
+	honeycomb.BUTTON_GEAR_UP:       {(*xplaneService).gearMoved, "up"},
+	honeycomb.BUTTON_GEAR_DOWN:     {(*xplaneService).gearMoved, "down"},
+	honeycomb.BUTTON_FLAPS_UP:      {(*xplaneService).flapsMoved, "up"},
+	honeycomb.BUTTON_FLAPS_DOWN:    {(*xplaneService).flapsMoved, "down"},
+	honeycomb.BUTTON_SWITCH_1_UP:   {(*xplaneService).switchMoved, "switch_1_up"},
+	honeycomb.BUTTON_SWITCH_1_DOWN: {(*xplaneService).switchMoved, "switch_1_down"},
+	honeycomb.BUTTON_SWITCH_2_UP:   {(*xplaneService).switchMoved, "switch_2_up"},
+	honeycomb.BUTTON_SWITCH_2_DOWN: {(*xplaneService).switchMoved, "switch_2_down"},
+	honeycomb.BUTTON_SWITCH_3_UP:   {(*xplaneService).switchMoved, "switch_3_up"},
+	honeycomb.BUTTON_SWITCH_3_DOWN: {(*xplaneService).switchMoved, "switch_3_down"},
+	honeycomb.BUTTON_SWITCH_4_UP:   {(*xplaneService).switchMoved, "switch_4_up"},
+	honeycomb.BUTTON_SWITCH_4_DOWN: {(*xplaneService).switchMoved, "switch_4_down"},
+	honeycomb.BUTTON_SWITCH_5_UP:   {(*xplaneService).switchMoved, "switch_5_up"},
+	honeycomb.BUTTON_SWITCH_5_DOWN: {(*xplaneService).switchMoved, "switch_5_down"},
+	honeycomb.BUTTON_SWITCH_6_UP:   {(*xplaneService).switchMoved, "switch_6_up"},
+	honeycomb.BUTTON_SWITCH_6_DOWN: {(*xplaneService).switchMoved, "switch_6_down"},
+	honeycomb.BUTTON_SWITCH_7_UP:   {(*xplaneService).switchMoved, "switch_7_up"},
+	honeycomb.BUTTON_SWITCH_7_DOWN: {(*xplaneService).switchMoved, "switch_7_down"},
+}
+
+// processInput drains the events read from the Bravo. It runs on the flight
+// loop so the handlers can talk to X-Plane. Lever positions are always
+// tracked; buttons are only dispatched when direct input is enabled and a
+// profile is loaded, otherwise they are dropped.
+func (s *xplaneService) processInput() {
+	if s.BravoService == nil {
+		return
+	}
+
+	input := s.BravoService.Input()
+	for {
+		select {
+		case event := <-input:
+			s.trackLeverInput(event)
+			if s.directInput && s.profile != nil {
+				s.handleInputEvent(event)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *xplaneService) handleInputEvent(event honeycomb.InputEvent) {
+	var phase utilities.CommandPhase
+	switch event.Kind {
+	case honeycomb.ButtonPressed:
+		phase = utilities.Phase_CommandBegin
+	case honeycomb.ButtonReleased:
+		phase = utilities.Phase_CommandEnd
+	default:
+		// lever positions are written by updateLevers
+		return
+	}
+
+	binding, ok := inputBindings[event.Button]
+	if !ok {
+		return
+	}
+	s.Logger.Debugf("Bravo input: %s", event)
+	binding.handler(s, nil, phase, binding.ref)
+}
@@ -0,0 +1,87 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
+)
+
+type stubBravoService struct {
+	panel *honeycomb.PanelState
+	input chan honeycomb.InputEvent
+}
+
+func newStubBravoService() *stubBravoService {
+	return &stubBravoService{
+		panel: honeycomb.NewPanelState(),
+		input: make(chan honeycomb.InputEvent, 16),
+	}
+}
+
+func (b *stubBravoService) UpdateLeds()                        {}
+func (b *stubBravoService) Panel() *honeycomb.PanelState       { return b.panel }
+func (b *stubBravoService) Input() <-chan honeycomb.InputEvent { return b.input }
+func (b *stubBravoService) Exit()                              {}
+
+func newInputTestService(bravo honeycomb.BravoService) *xplaneService {
+	mockLogger := new(MockLogger)
+	mockLogger.On("Debugf", mock.Anything, mock.Anything).Return()
+	return &xplaneService{
+		Logger:       mockLogger,
+		BravoService: bravo,
+		profile:      &pkg.Profile{},
+	}
+}
+
+func TestProcessInputSelectsApMode(t *testing.T) {
+	bravo := newStubBravoService()
+	s := newInputTestService(bravo)
+	s.directInput = true
+
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.ButtonPressed, Button: honeycomb.BUTTON_SELECTOR_VS}
+	s.processInput()
+
+	assert.Equal(t, "vs", s.apSelector)
+	assert.Empty(t, bravo.input)
+}
+
+func TestProcessInputDropsEventsWhenDisabled(t *testing.T) {
+	bravo := newStubBravoService()
+	s := newInputTestService(bravo)
+
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.ButtonPressed, Button: honeycomb.BUTTON_SELECTOR_HDG}
+	s.processInput()
+
+	assert.Equal(t, "", s.apSelector)
+	assert.Empty(t, bravo.input)
+}
+
+func TestSwitchInputQueuesConfiguredCommands(t *testing.T) {
+	bravo := newStubBravoService()
+	s := newInputTestService(bravo)
+	s.directInput = true
+	s.profile.Switches = &pkg.Switches{
+		SWITCH_3: pkg.SwitchProfile{
+			Up:   pkg.ActionProfile{Commands: []pkg.Command{{CommandStr: "sim/lights/beacon_lights_on"}}},
+			Down: pkg.ActionProfile{Commands: []pkg.Command{{CommandStr: "sim/lights/beacon_lights_off"}}},
+		},
+	}
+
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.ButtonPressed, Button: honeycomb.BUTTON_SWITCH_3_DOWN}
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.ButtonReleased, Button: honeycomb.BUTTON_SWITCH_3_DOWN}
+	s.processInput()
+
+	assert.Equal(t, []queuedCommand{{cmdStr: "sim/lights/beacon_lights_off", hold: defaultCommandHold}}, s.cmdEventQueue)
+}
+
+func TestGetSwitchActionRejectsUnknownRefs(t *testing.T) {
+	s := newInputTestService(newStubBravoService())
+	s.profile.Switches = &pkg.Switches{}
+
+	assert.Same(t, &s.profile.Switches.SWITCH_7.Up, s.getSwitchAction("switch_7_up"))
+	assert.Nil(t, s.getSwitchAction("switch_8_up"))
+	assert.Nil(t, s.getSwitchAction("switch_1_left"))
+}
@@ -0,0 +1,252 @@
+package xplane
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/xairline/goplane/xplm/utilities"
+)
+
+const (
+	defaultMachStep = 0.01
+	// roundingTolerance keeps values that are already on the step grid from
+	// being snapped to the previous step by floating point errors.
+	roundingTolerance = 1e-6
+)
+
+// defaultKnobAcceleration is used by knobs without an acceleration block. ALT
+// turns faster since its step is larger.
+var defaultKnobAcceleration = pkg.KnobAcceleration{
+	Steps: []pkg.KnobAccelerationStep{
+		{WithinMs: 100, Multiplier: 5},
+		{WithinMs: 200, Multiplier: 3},
+	},
+}
+
+var defaultAltKnobAcceleration = pkg.KnobAcceleration{
+	Steps: []pkg.KnobAccelerationStep{
+		{WithinMs: 100, Multiplier: 25},
+		{WithinMs: 200, Multiplier: 6},
+	},
+}
+
+// loadKnobAcceleration checks an acceleration block and sorts its steps from
+// the shortest window up.
+func loadKnobAcceleration(acceleration *pkg.KnobAcceleration) error {
+	for i, step := range acceleration.Steps {
+		if step.WithinMs <= 0 {
+			return fmt.Errorf("steps[%d]: within_ms must be above 0, got %d", i, step.WithinMs)
+		}
+		if step.Multiplier <= 0 {
+			return fmt.Errorf("steps[%d]: multiplier must be above 0, got %v", i, step.Multiplier)
+		}
+	}
+	if len(acceleration.Steps) == 0 && acceleration.Sensitivity == nil && acceleration.WindowMs == nil {
+		return fmt.Errorf("needs steps, sensitivity or window_ms")
+	}
+	sort.Slice(acceleration.Steps, func(i, j int) bool {
+		return acceleration.Steps[i].WithinMs < acceleration.Steps[j].WithinMs
+	})
+	return nil
+}
+
+// knobMultiplier returns how many steps a knob turn elapsedMs after the
+// previous one moves. The first turn always moves one step.
+func knobMultiplier(acceleration *pkg.KnobAcceleration, elapsedMs int64, first bool) float64 {
+	if first {
+		return 1.0
+	}
+
+	if len(acceleration.Steps) > 0 {
+		for _, step := range acceleration.Steps {
+			if elapsedMs < int64(step.WithinMs) {
+				return step.Multiplier
+			}
+		}
+		return 1.0
+	}
+
+	// continuous curve, same as the trim wheels
+	sensitivity := defaultTrimSensitivity
+	if acceleration.Sensitivity != nil {
+		sensitivity = *acceleration.Sensitivity
+	}
+	windowMs := defaultTrimWindowMs
+	if acceleration.WindowMs != nil {
+		windowMs = int64(*acceleration.WindowMs)
+	}
+	if sensitivity < minimumTrimSensitivity {
+		sensitivity = minimumTrimSensitivity
+	}
+	if windowMs < minimumTrimWindowMs {
+		windowMs = minimumTrimWindowMs
+	}
+	if elapsedMs >= windowMs {
+		return 1.0
+	}
+	// whole steps only, so values stay on the step grid
+	multiplier := sensitivity - ((sensitivity - minimumTrimSensitivity) * float64(elapsedMs) / float64(windowMs))
+	return math.Max(math.Floor(multiplier), 1.0)
+}
+
+// loadKnobCommands moves the older commands list into increase/decrease and
+// checks that every command comes with its opposite.
+func loadKnobCommands(profile *pkg.KnobProfile) error {
+	if len(profile.Commands) > 0 {
+		if len(profile.Commands) != 2 {
+			return fmt.Errorf("commands needs an increase and a decrease command, got %d", len(profile.Commands))
+		}
+		if len(profile.Increase) > 0 || len(profile.Decrease) > 0 {
+			return fmt.Errorf("use either commands or increase/decrease")
+		}
+		profile.Increase = profile.Commands[:1]
+		profile.Decrease = profile.Commands[1:]
+		profile.Commands = nil
+	}
+
+	pairs := []struct {
+		name     string
+		up, down []pkg.Command
+	}{
+		{"increase/decrease", profile.Increase, profile.Decrease},
+		{"coarse_increase/coarse_decrease", profile.CoarseIncrease, profile.CoarseDecrease},
+	}
+	for _, pair := range pairs {
+		if (len(pair.up) == 0) != (len(pair.down) == 0) {
+			return fmt.Errorf("%s must be set together", pair.name)
+		}
+	}
+	if len(profile.CoarseIncrease) > 0 && len(profile.Increase) == 0 {
+		return fmt.Errorf("coarse_increase/coarse_decrease need increase/decrease for slow turns")
+	}
+
+	lists := map[string][]pkg.Command{
+		"increase":        profile.Increase,
+		"decrease":        profile.Decrease,
+		"coarse_increase": profile.CoarseIncrease,
+		"coarse_decrease": profile.CoarseDecrease,
+		"push":            profile.Push,
+		"pull":            profile.Pull,
+	}
+	for name, commands := range lists {
+		for i, cmd := range commands {
+			if cmd.CommandStr == "" {
+				return fmt.Errorf("%s[%d]: missing command_str", name, i)
+			}
+			if err := validateQueuedCommand(cmd); err != nil {
+				return fmt.Errorf("%s[%d]: %v", name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveKnobCommands looks up every knob command once. Commands X-Plane does
+// not know are logged and dropped, so a typo never reaches XPLM.
+func (s *xplaneService) resolveKnobCommands(fieldName string, commands *pkg.KnobCommands) {
+	lists := []struct {
+		name     string
+		commands *[]pkg.Command
+	}{
+		{"increase", &commands.Increase},
+		{"decrease", &commands.Decrease},
+		{"coarse_increase", &commands.CoarseIncrease},
+		{"coarse_decrease", &commands.CoarseDecrease},
+		{"push", &commands.Push},
+		{"pull", &commands.Pull},
+	}
+	for _, list := range lists {
+		var resolved []pkg.Command
+		for i, cmd := range *list.commands {
+			ref := utilities.FindCommand(cmd.CommandStr)
+			if ref == nil {
+				s.Logger.Errorf("%s.%s[%d]: Command not found: %s", fieldName, list.name, i, cmd.CommandStr)
+				continue
+			}
+			cmd.Command = ref
+			resolved = append(resolved, cmd)
+		}
+		*list.commands = resolved
+	}
+}
+
+// knobTurnCommands returns the commands for one knob turn and how many times
+// to fire them.
+func knobTurnCommands(commands *pkg.KnobCommands, direction int, multiplier float64) ([]pkg.Command, int) {
+	fine, coarse := commands.Increase, commands.CoarseIncrease
+	if direction < 0 {
+		fine, coarse = commands.Decrease, commands.CoarseDecrease
+	}
+	if multiplier > 1 && len(coarse) > 0 {
+		return coarse, 1
+	}
+	return fine, int(multiplier)
+}
+
+func validateKnobLimits(limits pkg.KnobLimits) error {
+	if limits.Min != nil && limits.Max != nil && *limits.Min > *limits.Max {
+		return fmt.Errorf("min %v is above max %v", *limits.Min, *limits.Max)
+	}
+	if limits.Wrap && (limits.Min == nil || limits.Max == nil || *limits.Min == *limits.Max) {
+		return fmt.Errorf("wrap needs a min and a max")
+	}
+	if limits.RoundTo < 0 {
+		return fmt.Errorf("round_to must not be negative, got %v", limits.RoundTo)
+	}
+	return nil
+}
+
+// knobStepAndLimits returns the step and limits for a knob turn. While the
+// Mach changeover dataref is set, the Mach ones are used instead.
+func (s *xplaneService) knobStepAndLimits(myProfile *pkg.KnobProfile, step float64) (float64, pkg.KnobLimits) {
+	if myProfile.Mach == nil {
+		return step, myProfile.KnobLimits
+	}
+	isMach, ok := s.readDatarefValue(&myProfile.Mach.Dataref)
+	if !ok || isMach == 0 {
+		return step, myProfile.KnobLimits
+	}
+
+	machStep := float64(defaultMachStep)
+	if myProfile.Mach.Step > 0 {
+		machStep = float64(myProfile.Mach.Step)
+	}
+	return machStep, myProfile.Mach.KnobLimits
+}
+
+// knobValue returns current moved by delta, snapped to the step grid in the
+// direction of the turn and kept within the limits. A turn always moves at
+// least one grid line, so steps smaller than round_to still move the knob.
+func knobValue(current float64, delta float64, limits pkg.KnobLimits) float64 {
+	value := current + delta
+
+	if limits.RoundTo > 0 && delta != 0 {
+		roundTo := float64(limits.RoundTo)
+		lines := math.Max(1, math.Round(math.Abs(delta)/roundTo))
+		if delta > 0 {
+			value = math.Floor(current/roundTo+roundingTolerance)*roundTo + lines*roundTo
+		} else {
+			value = math.Ceil(current/roundTo-roundingTolerance)*roundTo - lines*roundTo
+		}
+	}
+
+	if limits.Wrap {
+		minValue, maxValue := float64(*limits.Min), float64(*limits.Max)
+		span := maxValue - minValue
+		value = minValue + math.Mod(value-minValue, span)
+		if value < minValue {
+			value += span
+		}
+		return value
+	}
+
+	if limits.Min != nil && value < float64(*limits.Min) {
+		value = float64(*limits.Min)
+	}
+	if limits.Max != nil && value > float64(*limits.Max) {
+		value = float64(*limits.Max)
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+func TestKnobValue(t *testing.T) {
+	heading := pkg.KnobLimits{Min: float32Ptr(0), Max: float32Ptr(360), Wrap: true}
+	altitude := pkg.KnobLimits{Min: float32Ptr(0), Max: float32Ptr(50000), RoundTo: 100}
+	mach := pkg.KnobLimits{Min: float32Ptr(0.4), Max: float32Ptr(0.95), RoundTo: 0.01}
+
+	tests := []struct {
+		name    string
+		current float64
+		delta   float64
+		limits  pkg.KnobLimits
+		want    float64
+	}{
+		{"no limits", 250, -300, pkg.KnobLimits{}, -50},
+		{"heading wraps up", 359, 1, heading, 0},
+		{"heading wraps down", 0, -1, heading, 359},
+		{"heading wraps on fast turns", 355, 10, heading, 5},
+		{"altitude snaps up to the grid", 1050, 100, altitude, 1100},
+		{"altitude snaps down to the grid", 1050, -100, altitude, 1000},
+		{"altitude on the grid", 1100, 500, altitude, 1600},
+		{"altitude step below round_to moves up", 1000, 50, altitude, 1100},
+		{"altitude step below round_to moves down", 1000, -50, altitude, 900},
+		{"altitude stays above min", 50, -100, altitude, 0},
+		{"altitude stays below max", 49950, 500, altitude, 50000},
+		{"ias stays above min", 5, -10, pkg.KnobLimits{Min: float32Ptr(0)}, 0},
+		{"mach steps on the grid", 0.78, 0.01, mach, 0.79},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, knobValue(tt.current, tt.delta, tt.limits), 1e-4)
+		})
+	}
+}
+
+func TestValidateKnobLimits(t *testing.T) {
+	assert.NoError(t, validateKnobLimits(pkg.KnobLimits{}))
+	assert.NoError(t, validateKnobLimits(pkg.KnobLimits{Min: float32Ptr(0), Max: float32Ptr(360), Wrap: true}))
+	assert.Error(t, validateKnobLimits(pkg.KnobLimits{Min: float32Ptr(10), Max: float32Ptr(0)}))
+	assert.Error(t, validateKnobLimits(pkg.KnobLimits{Max: float32Ptr(360), Wrap: true}))
+	assert.Error(t, validateKnobLimits(pkg.KnobLimits{RoundTo: -1}))
+}
+
+func TestKnobMultiplier(t *testing.T) {
+	sensitivity := 10.0
+	window := 400
+	curve := &pkg.KnobAcceleration{Sensitivity: &sensitivity, WindowMs: &window}
+	custom := &pkg.KnobAcceleration{Steps: []pkg.KnobAccelerationStep{
+		{WithinMs: 300, Multiplier: 2},
+		{WithinMs: 50, Multiplier: 10},
+	}}
+	assert.NoError(t, loadKnobAcceleration(custom))
+
+	tests := []struct {
+		name         string
+		acceleration *pkg.KnobAcceleration
+		elapsedMs    int64
+		first        bool
+		want         float64
+	}{
+		{"first turn", &defaultKnobAcceleration, 10, true, 1},
+		{"default fast", &defaultKnobAcceleration, 50, false, 5},
+		{"default medium", &defaultKnobAcceleration, 150, false, 3},
+		{"default slow", &defaultKnobAcceleration, 250, false, 1},
+		{"alt fast", &defaultAltKnobAcceleration, 50, false, 25},
+		{"alt medium", &defaultAltKnobAcceleration, 150, false, 6},
+		{"custom steps are sorted", custom, 20, false, 10},
+		{"custom second step", custom, 200, false, 2},
+		{"curve at start", curve, 0, false, 10},
+		{"curve halfway", curve, 200, false, 5},
+		{"curve past window", curve, 500, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, knobMultiplier(tt.acceleration, tt.elapsedMs, tt.first))
+		})
+	}
+}
+
+func TestLoadKnobAccelerationRejectsInvalidSteps(t *testing.T) {
+	assert.Error(t, loadKnobAcceleration(&pkg.KnobAcceleration{}))
+	assert.Error(t, loadKnobAcceleration(&pkg.KnobAcceleration{Steps: []pkg.KnobAccelerationStep{{WithinMs: 0, Multiplier: 2}}}))
+	assert.Error(t, loadKnobAcceleration(&pkg.KnobAcceleration{Steps: []pkg.KnobAccelerationStep{{WithinMs: 100, Multiplier: 0}}}))
+}
+
+func TestLoadKnobCommands(t *testing.T) {
+	legacy := &pkg.KnobProfile{Commands: []pkg.Command{{CommandStr: "up"}, {CommandStr: "down"}}}
+	assert.NoError(t, loadKnobCommands(legacy))
+	assert.Equal(t, []pkg.Command{{CommandStr: "up"}}, legacy.Increase)
+	assert.Equal(t, []pkg.Command{{CommandStr: "down"}}, legacy.Decrease)
+	assert.Nil(t, legacy.Commands)
+
+	invalid := []*pkg.KnobProfile{
+		{Commands: []pkg.Command{{CommandStr: "up"}}},
+		{Commands: []pkg.Command{{CommandStr: "up"}, {CommandStr: "down"}}, KnobCommands: pkg.KnobCommands{Increase: []pkg.Command{{CommandStr: "up"}}}},
+		{KnobCommands: pkg.KnobCommands{Increase: []pkg.Command{{CommandStr: "up"}}}},
+		{KnobCommands: pkg.KnobCommands{CoarseIncrease: []pkg.Command{{CommandStr: "up"}}, CoarseDecrease: []pkg.Command{{CommandStr: "down"}}}},
+		{KnobCommands: pkg.KnobCommands{Push: []pkg.Command{{}}}},
+	}
+	for i, profile := range invalid {
+		assert.Error(t, loadKnobCommands(profile), "profile %d", i)
+	}
+}
+
+func TestKnobTurnCommands(t *testing.T) {
+	commands := &pkg.KnobCommands{
+		Increase:       []pkg.Command{{CommandStr: "alt_up_100"}},
+		Decrease:       []pkg.Command{{CommandStr: "alt_down_100"}},
+		CoarseIncrease: []pkg.Command{{CommandStr: "alt_up_1000"}},
+		CoarseDecrease: []pkg.Command{{CommandStr: "alt_down_1000"}},
+	}
+
+	cmds, repeat := knobTurnCommands(commands, 1, 1)
+	assert.Equal(t, "alt_up_100", cmds[0].CommandStr)
+	assert.Equal(t, 1, repeat)
+
+	cmds, repeat = knobTurnCommands(commands, -1, 5)
+	assert.Equal(t, "alt_down_1000", cmds[0].CommandStr)
+	assert.Equal(t, 1, repeat)
+
+	commands.CoarseIncrease = nil
+	cmds, repeat = knobTurnCommands(commands, 1, 3)
+	assert.Equal(t, "alt_up_100", cmds[0].CommandStr)
+	assert.Equal(t, 3, repeat)
+
+	cmds, _ = knobTurnCommands(&pkg.KnobCommands{}, 1, 1)
+	assert.Empty(t, cmds)
+}
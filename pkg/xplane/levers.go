@@ -0,0 +1,258 @@
+package xplane
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
+)
+
+const (
+	leverCount         = 6
+	maxEngines         = 16
+	defaultDetentWidth = 0.02
+	// leverWriteEpsilon is how much a lever output has to change before it
+	// is written again.
+	leverWriteEpsilon = 0.001
+
+	throttleReverseDataref = "sim/cockpit2/engine/actuators/throttle_jet_rev_ratio"
+)
+
+// leverRoleDatarefs are the datarefs written for the built-in lever roles.
+// Per-engine roles are arrays indexed by engine number.
+var leverRoleDatarefs = map[string]string{
+	"throttle":   "sim/cockpit2/engine/actuators/throttle_ratio",
+	"prop":       "sim/cockpit2/engine/actuators/prop_ratio",
+	"mixture":    "sim/cockpit2/engine/actuators/mixture_ratio",
+	"speedbrake": "sim/cockpit2/controls/speedbrake_ratio",
+}
+
+var leverReverseButtons = [leverCount]honeycomb.Button{
+	honeycomb.BUTTON_LEVER_1_DETENT,
+	honeycomb.BUTTON_LEVER_2_DETENT,
+	honeycomb.BUTTON_LEVER_3_DETENT,
+	honeycomb.BUTTON_LEVER_4_DETENT,
+	honeycomb.BUTTON_LEVER_5_DETENT,
+	honeycomb.BUTTON_LEVER_6_DETENT,
+}
+
+// leverState is the last known position of every lever and whether it sits
+// in its reverse detent, and the value last written to each target.
+type leverState struct {
+	position [leverCount]float32
+	known    [leverCount]bool
+	reverse  [leverCount]bool
+	written  map[*pkg.Dataref]float32
+}
+
+// changed tells if value differs enough from what was last written to target
+// and records it if so.
+func (l *leverState) changed(target *pkg.Dataref, value float32) bool {
+	if last, ok := l.written[target]; ok && math.Abs(float64(value-last)) <= leverWriteEpsilon {
+		return false
+	}
+	if l.written == nil {
+		l.written = map[*pkg.Dataref]float32{}
+	}
+	l.written[target] = value
+	return true
+}
+
+// trackLeverInput keeps leverState up to date. It sees every event, so the
+// lever positions are known as soon as a profile is loaded.
+func (s *xplaneService) trackLeverInput(event honeycomb.InputEvent) {
+	switch event.Kind {
+	case honeycomb.AxisMoved:
+		if int(event.Axis) < leverCount {
+			s.levers.position[event.Axis] = event.Value
+			s.levers.known[event.Axis] = true
+		}
+	case honeycomb.ButtonPressed, honeycomb.ButtonReleased:
+		for i, button := range leverReverseButtons {
+			if event.Button == button {
+				s.levers.reverse[i] = event.Kind == honeycomb.ButtonPressed
+			}
+		}
+	}
+}
+
+// updateLevers writes the lever positions to their target datarefs. A target
+// is only written when the lever output changes, so the autothrottle and
+// other plugins can drive it while the lever rests. Targets that did not
+// resolve were logged by loadLeverProfiles and are skipped.
+func (s *xplaneService) updateLevers() {
+	if !s.directInput || s.profile == nil {
+		// write every lever again once direct input is back on
+		s.levers.written = nil
+		return
+	}
+
+	for i := range s.profile.Levers {
+		lever := &s.profile.Levers[i]
+		index := lever.Lever - 1
+		if index < 0 || index >= leverCount || !s.levers.known[index] {
+			continue
+		}
+
+		value := leverOutput(lever, s.levers.position[index], s.levers.reverse[index])
+		for j := range lever.Targets {
+			target := &lever.Targets[j]
+			handle := datarefOf(target.Dataref)
+			if handle == nil || !s.levers.changed(target, value) {
+				continue
+			}
+			if err := handle.set(target.Index, float64(value)); err != nil {
+				// logged once, the target is skipped from now on
+				s.Logger.Errorf("levers: %v", err)
+				target.Dataref = nil
+			}
+		}
+	}
+}
+
+// leverOutput maps a raw lever position (0..1) to the value written to the
+// lever's datarefs.
+func leverOutput(lever *pkg.LeverProfile, position float32, inReverse bool) float32 {
+	p := scaleToUnit(position, lever.Calibration)
+	p = applyDeadZone(p, lever.DeadZone)
+
+	if inReverse && lever.Reverse.Enabled {
+		// in the reverse detent, pushing the lever forward adds reverse thrust
+		max := lever.Reverse.Max
+		if max == 0 {
+			max = 1
+		}
+		return -applyCurve(p, lever.Curve) * max
+	}
+
+	for _, detent := range lever.Detents {
+		width := detent.Width
+		if width == 0 {
+			width = defaultDetentWidth
+		}
+		if float32(math.Abs(float64(p-detent.Position))) <= width {
+			if detent.Value != nil {
+				return *detent.Value
+			}
+			p = detent.Position
+			break
+		}
+	}
+
+	p = applyCurve(p, lever.Curve)
+	if lever.Output == (pkg.LeverRange{}) {
+		return p
+	}
+	return lever.Output.Min + p*(lever.Output.Max-lever.Output.Min)
+}
+
+// scaleToUnit maps the calibrated range to 0..1 and clamps the result.
+func scaleToUnit(position float32, calibration pkg.LeverRange) float32 {
+	p := position
+	if calibration != (pkg.LeverRange{}) {
+		p = (position - calibration.Min) / (calibration.Max - calibration.Min)
+	}
+	return clampUnit(p)
+}
+
+// applyDeadZone pins positions within deadZone of either end to that end and
+// spreads the rest over the full range.
+func applyDeadZone(p float32, deadZone float32) float32 {
+	if deadZone <= 0 {
+		return p
+	}
+	return clampUnit((p - deadZone) / (1 - 2*deadZone))
+}
+
+// applyCurve raises p to the curve exponent; 0 and 1 mean linear.
+func applyCurve(p float32, curve float32) float32 {
+	if curve <= 0 || curve == 1 {
+		return p
+	}
+	return float32(math.Pow(float64(p), float64(curve)))
+}
+
+func clampUnit(p float32) float32 {
+	if p < 0 {
+		return 0
+	}
+	if p > 1 {
+		return 1
+	}
+	return p
+}
+
+func (s *xplaneService) loadLeverProfiles(levers []pkg.LeverProfile) error {
+	s.levers.written = nil
+	for i := range levers {
+		lever := &levers[i]
+		name := fmt.Sprintf("levers[%d]", i)
+		if err := validateLeverProfile(lever); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		s.Logger.Infof("-- Loading Lever: %s (lever %d, %s)", name, lever.Lever, lever.Role)
+
+		var targets []pkg.Dataref
+		switch lever.Role {
+		case "custom":
+			targets = append(targets, pkg.Dataref{DatarefStr: lever.DatarefStr, Index: lever.Index})
+		case "speedbrake":
+			targets = append(targets, pkg.Dataref{DatarefStr: leverRoleDatarefs[lever.Role]})
+		default:
+			datarefStr := leverRoleDatarefs[lever.Role]
+			if lever.Role == "throttle" && lever.Reverse.Enabled {
+				datarefStr = throttleReverseDataref
+			}
+			engines := lever.Engines
+			if len(engines) == 0 {
+				engines = []int{1}
+			}
+			for _, engine := range engines {
+				targets = append(targets, pkg.Dataref{DatarefStr: datarefStr, Index: engine - 1})
+			}
+		}
+
+		// getDataref logs the datarefs it cannot find, they are left out here
+		lever.Targets = nil
+		for _, target := range targets {
+			handle := s.getDataref(target.DatarefStr)
+			if handle == nil {
+				continue
+			}
+			target.Dataref = handle
+			lever.Targets = append(lever.Targets, target)
+		}
+	}
+	return nil
+}
+
+func validateLeverProfile(lever *pkg.LeverProfile) error {
+	if lever.Lever < 1 || lever.Lever > leverCount {
+		return fmt.Errorf("Lever must be between 1 and %d, got %d", leverCount, lever.Lever)
+	}
+	if _, ok := leverRoleDatarefs[lever.Role]; !ok && lever.Role != "custom" {
+		return fmt.Errorf("Unsupported lever role: %s", lever.Role)
+	}
+	if lever.Role == "custom" && lever.DatarefStr == "" {
+		return fmt.Errorf("Custom lever role needs a dataref_str")
+	}
+	for _, engine := range lever.Engines {
+		if engine < 1 || engine > maxEngines {
+			return fmt.Errorf("Engine must be between 1 and %d, got %d", maxEngines, engine)
+		}
+	}
+	if lever.Calibration != (pkg.LeverRange{}) && lever.Calibration.Max <= lever.Calibration.Min {
+		return fmt.Errorf("Calibration max must be greater than min")
+	}
+	if lever.DeadZone < 0 || lever.DeadZone >= 0.5 {
+		return fmt.Errorf("Dead zone must be between 0 and 0.5, got %f", lever.DeadZone)
+	}
+	if lever.Curve < 0 {
+		return fmt.Errorf("Curve must not be negative, got %f", lever.Curve)
+	}
+	if lever.Reverse.Enabled && lever.Role != "throttle" {
+		return fmt.Errorf("Reverse is only supported for the throttle role")
+	}
+	return nil
+}
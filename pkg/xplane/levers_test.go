@@ -0,0 +1,123 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
+	"github.com/xairline/goplane/xplm/dataAccess"
+)
+
+func TestLeverOutputAppliesCalibrationAndDeadZone(t *testing.T) {
+	lever := &pkg.LeverProfile{
+		Calibration: pkg.LeverRange{Min: 0.1, Max: 0.9},
+		DeadZone:    0.05,
+	}
+
+	assert.Equal(t, float32(0), leverOutput(lever, 0.05, false))
+	assert.Equal(t, float32(0), leverOutput(lever, 0.12, false))
+	assert.InDelta(t, 0.5, leverOutput(lever, 0.5, false), 0.0001)
+	assert.Equal(t, float32(1), leverOutput(lever, 0.89, false))
+}
+
+func TestLeverOutputAppliesCurveAndOutputRange(t *testing.T) {
+	lever := &pkg.LeverProfile{
+		Curve:  2,
+		Output: pkg.LeverRange{Min: 0.5, Max: 1.5},
+	}
+
+	assert.InDelta(t, 0.75, leverOutput(lever, 0.5, false), 0.0001)
+}
+
+func TestLeverOutputSnapsToDetents(t *testing.T) {
+	flx := float32(0.87)
+	lever := &pkg.LeverProfile{
+		Detents: []pkg.LeverDetent{
+			{Position: 0.7},
+			{Position: 0.85, Width: 0.05, Value: &flx},
+		},
+	}
+
+	assert.Equal(t, float32(0.7), leverOutput(lever, 0.71, false))
+	assert.Equal(t, float32(0.87), leverOutput(lever, 0.82, false))
+	assert.Equal(t, float32(0.5), leverOutput(lever, 0.5, false))
+}
+
+func TestLeverOutputReverse(t *testing.T) {
+	lever := &pkg.LeverProfile{
+		Role:    "throttle",
+		Reverse: pkg.LeverReverse{Enabled: true, Max: 0.8},
+	}
+
+	assert.InDelta(t, -0.4, leverOutput(lever, 0.5, true), 0.0001)
+	assert.InDelta(t, 0.5, leverOutput(lever, 0.5, false), 0.0001)
+
+	lever.Reverse.Enabled = false
+	assert.InDelta(t, 0.5, leverOutput(lever, 0.5, true), 0.0001)
+}
+
+func TestValidateLeverProfile(t *testing.T) {
+	assert.NoError(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "throttle", Engines: []int{1, 2}}))
+	assert.NoError(t, validateLeverProfile(&pkg.LeverProfile{Lever: 6, Role: "custom", Dataref: pkg.Dataref{DatarefStr: "sim/foo"}}))
+
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 7, Role: "throttle"}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "flaps"}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "custom"}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "throttle", Engines: []int{0}}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "throttle", Calibration: pkg.LeverRange{Min: 0.9, Max: 0.1}}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "throttle", DeadZone: 0.5}))
+	assert.Error(t, validateLeverProfile(&pkg.LeverProfile{Lever: 1, Role: "mixture", Reverse: pkg.LeverReverse{Enabled: true}}))
+}
+
+func TestProcessInputTracksLevers(t *testing.T) {
+	bravo := newStubBravoService()
+	s := newInputTestService(bravo)
+
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.AxisMoved, Axis: honeycomb.AXIS_LEVER_2, Value: 0.4}
+	bravo.input <- honeycomb.InputEvent{Kind: honeycomb.ButtonPressed, Button: honeycomb.BUTTON_LEVER_2_DETENT}
+	s.processInput()
+
+	assert.True(t, s.levers.known[1])
+	assert.Equal(t, float32(0.4), s.levers.position[1])
+	assert.True(t, s.levers.reverse[1])
+	assert.False(t, s.levers.known[0])
+}
+
+func TestLoadLeverProfilesSkipsMissingTargets(t *testing.T) {
+	mockLogger := new(MockLogger)
+	mockLogger.On("Infof", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Errorf", mock.Anything, mock.Anything).Return()
+	s := &xplaneService{Logger: mockLogger}
+	s.datarefs.find = func(name string) (dataAccess.DataRef, dataAccess.DataRefType, bool) {
+		if name == "missing" {
+			return nil, dataAccess.TypeUnknown, false
+		}
+		return nil, dataAccess.TypeFloatArray, true
+	}
+	levers := []pkg.LeverProfile{
+		{Lever: 1, Role: "throttle", Engines: []int{1, 2}},
+		{Lever: 2, Role: "custom", Dataref: pkg.Dataref{DatarefStr: "missing"}},
+	}
+
+	assert.NoError(t, s.loadLeverProfiles(levers))
+
+	assert.Len(t, levers[0].Targets, 2)
+	assert.Equal(t, 1, levers[0].Targets[1].Index)
+	assert.NotNil(t, datarefOf(levers[0].Targets[1].Dataref))
+	assert.Empty(t, levers[1].Targets)
+	mockLogger.AssertNumberOfCalls(t, "Errorf", 1)
+}
+
+func TestLeverStateWritesOnlyChanges(t *testing.T) {
+	var levers leverState
+	throttle := &pkg.Dataref{DatarefStr: "sim/cockpit2/engine/actuators/throttle_ratio"}
+	prop := &pkg.Dataref{DatarefStr: "sim/cockpit2/engine/actuators/prop_ratio"}
+
+	assert.True(t, levers.changed(throttle, 0.5))
+	assert.False(t, levers.changed(throttle, 0.5))
+	assert.False(t, levers.changed(throttle, 0.5005))
+	assert.True(t, levers.changed(prop, 0.5))
+	assert.True(t, levers.changed(throttle, 0.6))
+}
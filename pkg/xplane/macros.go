@@ -0,0 +1,218 @@
+package xplane
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/xairline/goplane/xplm/utilities"
+)
+
+const (
+	buttonActionMacro        = "macro"
+	buttonActionWait         = "wait"
+	buttonActionCommandBegin = "command_begin"
+	buttonActionCommandEnd   = "command_end"
+)
+
+// macroRun is a macro in progress. Steps run in order in the flight loop,
+// a wait step pauses the run until resumeAt.
+type macroRun struct {
+	name     string
+	steps    []pkg.ButtonAction
+	next     int
+	resumeAt float64
+	begun    map[string]bool
+}
+
+// loadMacros checks every macro. They are loaded before anything that can
+// reference them.
+func (s *xplaneService) loadMacros(macros map[string][]pkg.ButtonAction) error {
+	s.macros = macros
+
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(macros[name]) == 0 {
+			return fmt.Errorf("macros.%s: no steps", name)
+		}
+		if err := s.loadButtonActions("macros."+name, macros[name], true); err != nil {
+			return err
+		}
+	}
+	return findMacroCycle(macros)
+}
+
+func (s *xplaneService) checkMacroRef(name string) error {
+	if _, ok := s.macros[name]; !ok {
+		return fmt.Errorf("Macro not found: %s", name)
+	}
+	return nil
+}
+
+// findMacroCycle returns an error if a macro runs itself, directly or
+// through other macros.
+func findMacroCycle(macros map[string][]pkg.ButtonAction) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+
+	var visit func(name string, path []string) error
+	var visitSteps func(steps []pkg.ButtonAction, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("Macro runs itself: %v", path)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		if err := visitSteps(macros[name], path); err != nil {
+			return err
+		}
+		state[name] = done
+		return nil
+	}
+	visitSteps = func(steps []pkg.ButtonAction, path []string) error {
+		for _, step := range steps {
+			if step.Type == buttonActionMacro {
+				if err := visit(step.Macro, path); err != nil {
+					return err
+				}
+			}
+			if err := visitSteps(step.Actions, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(macros))
+	for name := range macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandMacro returns the steps of a macro with the bindings picked and the
+// macros it runs put in place.
+func expandMacro(macros map[string][]pkg.ButtonAction, name string, passes func(*pkg.ConditionProfile) bool) []pkg.ButtonAction {
+	var steps []pkg.ButtonAction
+	for _, step := range selectButtonActions(macros[name], passes) {
+		if step.Type == buttonActionMacro {
+			steps = append(steps, expandMacro(macros, step.Macro, passes)...)
+			continue
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// startMacro schedules a macro. A macro that is still running is not started
+// again.
+func (s *xplaneService) startMacro(name string) {
+	for _, run := range s.macroRuns {
+		if run.name == name {
+			s.Logger.Warningf("Macro %s is already running.", name)
+			return
+		}
+	}
+	passes := func(condition *pkg.ConditionProfile) bool {
+		result, valid := s.evaluateConditionRules(condition)
+		return valid && result
+	}
+	s.Logger.Debugf("Starting macro: %s", name)
+	s.macroRuns = append(s.macroRuns, &macroRun{
+		name:     name,
+		steps:    expandMacro(s.macros, name, passes),
+		resumeAt: s.globalTime,
+		begun:    map[string]bool{},
+	})
+}
+
+// advanceMacro runs the steps that are due at now and tells if the macro is
+// finished.
+func advanceMacro(run *macroRun, now float64, runStep func(*pkg.ButtonAction)) bool {
+	for run.next < len(run.steps) && now >= run.resumeAt {
+		step := &run.steps[run.next]
+		run.next++
+		if step.Type == buttonActionWait {
+			run.resumeAt = now + float64(step.Seconds)
+			continue
+		}
+		runStep(step)
+	}
+	return run.next >= len(run.steps) && now >= run.resumeAt
+}
+
+// updateMacros moves every running macro forward. It is called from the
+// flight loop.
+func (s *xplaneService) updateMacros() {
+	running := s.macroRuns[:0]
+	for _, run := range s.macroRuns {
+		finished := advanceMacro(run, s.globalTime, func(step *pkg.ButtonAction) {
+			s.runMacroStep(run, step)
+		})
+		if finished {
+			s.endMacro(run)
+			continue
+		}
+		running = append(running, run)
+	}
+	s.macroRuns = running
+}
+
+func (s *xplaneService) runMacroStep(run *macroRun, step *pkg.ButtonAction) {
+	switch step.Type {
+	case buttonActionCommandBegin, buttonActionCommandEnd:
+		cmd := utilities.FindCommand(step.CommandStr)
+		if cmd == nil {
+			s.Logger.Errorf("Command not found: %s", step.CommandStr)
+			return
+		}
+		if step.Type == buttonActionCommandBegin {
+			s.Logger.Debugf("Macro %s beginning command: %s", run.name, step.CommandStr)
+			utilities.CommandBegin(cmd)
+			run.begun[step.CommandStr] = true
+		} else if run.begun[step.CommandStr] {
+			s.Logger.Debugf("Macro %s ending command: %s", run.name, step.CommandStr)
+			utilities.CommandEnd(cmd)
+			delete(run.begun, step.CommandStr)
+		}
+	default:
+		s.runButtonAction(step)
+	}
+}
+
+// endMacro ends the commands a macro left begun.
+func (s *xplaneService) endMacro(run *macroRun) {
+	for cmdStr := range run.begun {
+		cmd := utilities.FindCommand(cmdStr)
+		if cmd == nil {
+			continue
+		}
+		s.Logger.Debugf("Macro %s ending command: %s", run.name, cmdStr)
+		utilities.CommandEnd(cmd)
+	}
+	s.Logger.Debugf("Finished macro: %s", run.name)
+}
+
+// stopMacros stops every running macro, e.g. when another profile is loaded.
+func (s *xplaneService) stopMacros() {
+	for _, run := range s.macroRuns {
+		s.endMacro(run)
+	}
+	s.macroRuns = nil
+}
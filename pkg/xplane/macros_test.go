@@ -0,0 +1,85 @@
+package xplane
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+)
+
+func TestFindMacroCycle(t *testing.T) {
+	lights := []pkg.ButtonAction{{CommandStr: "sim/lights/landing_lights_on"}}
+	assert.NoError(t, findMacroCycle(map[string][]pkg.ButtonAction{
+		"lights":  lights,
+		"takeoff": {{Type: buttonActionMacro, Macro: "lights"}, {Type: buttonActionMacro, Macro: "lights"}},
+	}))
+
+	assert.Error(t, findMacroCycle(map[string][]pkg.ButtonAction{
+		"a": {{Type: buttonActionMacro, Macro: "b"}},
+		"b": {{Type: buttonActionMacro, Macro: "a"}},
+	}))
+	assert.Error(t, findMacroCycle(map[string][]pkg.ButtonAction{
+		"a": {{Actions: []pkg.ButtonAction{{Type: buttonActionMacro, Macro: "a"}}}},
+	}))
+}
+
+func TestExpandMacro(t *testing.T) {
+	ap := pkg.ButtonAction{CommandStr: "sim/autopilot/servos_on"}
+	at := pkg.ButtonAction{CommandStr: "sim/autopilot/autothrottle_on"}
+	wait := pkg.ButtonAction{Type: buttonActionWait, Seconds: 1}
+	macros := map[string][]pkg.ButtonAction{
+		"at":    {at},
+		"ap_at": {ap, wait, {Type: buttonActionMacro, Macro: "at"}},
+	}
+	passes := func(*pkg.ConditionProfile) bool { return true }
+
+	assert.Equal(t, []pkg.ButtonAction{ap, wait, at}, expandMacro(macros, "ap_at", passes))
+}
+
+func TestAdvanceMacro(t *testing.T) {
+	run := &macroRun{steps: []pkg.ButtonAction{
+		{CommandStr: "first"},
+		{Type: buttonActionWait, Seconds: 0.5},
+		{CommandStr: "second"},
+		{Type: buttonActionWait, Seconds: 0.5},
+	}}
+	var ran []string
+	runStep := func(step *pkg.ButtonAction) {
+		ran = append(ran, step.CommandStr)
+	}
+
+	assert.False(t, advanceMacro(run, 1.0, runStep))
+	assert.Equal(t, []string{"first"}, ran)
+
+	assert.False(t, advanceMacro(run, 1.25, runStep))
+	assert.Equal(t, []string{"first"}, ran)
+
+	assert.False(t, advanceMacro(run, 1.5, runStep))
+	assert.Equal(t, []string{"first", "second"}, ran)
+
+	assert.True(t, advanceMacro(run, 2.0, runStep))
+	assert.Equal(t, []string{"first", "second"}, ran)
+}
+
+func TestValidateMacroSteps(t *testing.T) {
+	valid := []pkg.ButtonAction{
+		{Type: buttonActionMacro, Macro: "lights"},
+		{Type: buttonActionWait, Seconds: 0.5},
+		{Type: buttonActionCommandBegin, CommandStr: "sim/autopilot/take_off_go_around"},
+		{Type: buttonActionCommandEnd, CommandStr: "sim/autopilot/take_off_go_around"},
+	}
+	for i := range valid {
+		assert.NoError(t, validateButtonAction(&valid[i]), valid[i].Type)
+	}
+	assert.True(t, isMacroStep(&valid[1]))
+	assert.False(t, isMacroStep(&valid[0]))
+
+	invalid := []pkg.ButtonAction{
+		{Type: buttonActionMacro},
+		{Type: buttonActionWait},
+		{Type: buttonActionCommandBegin},
+	}
+	for i := range invalid {
+		assert.Error(t, validateButtonAction(&invalid[i]), invalid[i].Type)
+	}
+}
@@ -18,6 +18,17 @@ func (s *xplaneService) menuHandler(menuRef interface{}, itemRef interface{}) {
 		}
 
 	}
+	if itemId == 2 {
+		s.directInput = !s.directInput
+		if s.directInput {
+			s.Logger.Info("Direct Bravo input enabled")
+			menus.CheckMenuItem(s.myMenuId, s.inputMenuIndex, menus.Menu_Checked)
+		} else {
+			s.Logger.Info("Direct Bravo input disabled")
+			menus.CheckMenuItem(s.myMenuId, s.inputMenuIndex, menus.Menu_Unchecked)
+		}
+		s.saveSettings()
+	}
 	if itemId == 0 {
 		s.Logger.Info("Reload Profile Clicked")
 		s.profile = nil
@@ -58,6 +58,20 @@ func (s *xplaneService) onPluginStart() {
 	menus.AppendMenuItem(s.myMenuId, "Reload Profile", 0, true)
 	menus.AppendMenuSeparator(s.myMenuId)
 	s.myMenuItemIndex = menus.AppendMenuItem(s.myMenuId, "Enable Debug", 1, true)
+	// off until turned on once, buttons already bound in X-Plane would fire
+	// twice; the choice is kept in settings.yaml
+	s.inputMenuIndex = menus.AppendMenuItem(s.myMenuId, "Read Bravo Input Directly", 2, true)
+	settings, err := loadPluginSettings(pluginPath)
+	if err != nil {
+		s.Logger.Errorf("Failed to load settings: %v", err)
+	}
+	s.directInput = settings.DirectInput
+	if s.directInput {
+		s.Logger.Info("Direct Bravo input enabled")
+		menus.CheckMenuItem(s.myMenuId, s.inputMenuIndex, menus.Menu_Checked)
+	} else {
+		menus.CheckMenuItem(s.myMenuId, s.inputMenuIndex, menus.Menu_Unchecked)
+	}
 
 	if s.debug {
 		menus.CheckMenuItem(s.myMenuId, s.myMenuItemIndex, menus.Menu_Checked)
@@ -67,6 +81,8 @@ func (s *xplaneService) onPluginStart() {
 	s.setupKnobsCmds()
 	s.setupApCmds()
 	s.setupTrimCmds()
+	s.setupSwitchCmds()
+	s.setupGearAndFlapCmds()
 	s.checkForNewReleaseVersion()
 
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/expr-lang/expr"
@@ -87,6 +89,12 @@ func (s *xplaneService) tryLoadProfile() error {
 
 func (s *xplaneService) setupProfile(planeProfile pkg.Profile) error {
 	s.resetTolissTrimCommand()
+	s.releaseHeldButtons()
+	s.stopMacros()
+	s.datarefs.reset()
+	if s.BravoService != nil {
+		s.BravoService.Panel().ResetPatterns()
+	}
 
 	// Fill in any missing sections of the profile
 	if planeProfile.Metadata == nil {
@@ -104,10 +112,23 @@ func (s *xplaneService) setupProfile(planeProfile pkg.Profile) error {
 	if planeProfile.Leds == nil {
 		planeProfile.Leds = &pkg.Leds{}
 	}
+	if planeProfile.Switches == nil {
+		planeProfile.Switches = &pkg.Switches{}
+	}
+	if planeProfile.Buttons == nil {
+		planeProfile.Buttons = &pkg.Buttons{}
+	}
 
 	var err error
 	hasErrors := false
 
+	s.Logger.Infof("Loading Macros")
+	err = s.loadMacros(planeProfile.Macros)
+	if err != nil {
+		s.Logger.Errorf("Error loading Macros: %v", err)
+		hasErrors = true
+	}
+
 	s.Logger.Infof("Loading LEDs")
 	err = rangeStruct(planeProfile.Leds, s.loadProfileElement)
 	if err != nil {
@@ -136,6 +157,54 @@ func (s *xplaneService) setupProfile(planeProfile pkg.Profile) error {
 		hasErrors = true
 	}
 
+	s.Logger.Infof("Loading Buttons")
+	err = rangeStruct(planeProfile.Buttons, s.loadProfileElement)
+	if err != nil {
+		s.Logger.Errorf("Error loading Buttons: %v", err)
+		hasErrors = true
+	}
+	if planeProfile.Timing != nil {
+		err = validateButtonTiming(planeProfile.Timing)
+		if err != nil {
+			s.Logger.Errorf("Error loading Button Timing: %v", err)
+			hasErrors = true
+		}
+	}
+
+	s.Logger.Infof("Loading Switches")
+	err = rangeStruct(planeProfile.Switches, s.loadProfileElement)
+	if err != nil {
+		s.Logger.Errorf("Error loading Switches: %v", err)
+		hasErrors = true
+	}
+
+	if planeProfile.GearLever != nil {
+		s.Logger.Infof("Loading Gear Lever")
+		err = s.loadGearLeverProfile(planeProfile.GearLever)
+		if err != nil {
+			s.Logger.Errorf("Error loading Gear Lever: %v", err)
+			hasErrors = true
+		}
+	}
+
+	if planeProfile.FlapLever != nil {
+		s.Logger.Infof("Loading Flap Lever")
+		err = s.loadFlapLeverProfile(planeProfile.FlapLever)
+		if err != nil {
+			s.Logger.Errorf("Error loading Flap Lever: %v", err)
+			hasErrors = true
+		}
+	}
+
+	if len(planeProfile.Levers) > 0 {
+		s.Logger.Infof("Loading Levers")
+		err = s.loadLeverProfiles(planeProfile.Levers)
+		if err != nil {
+			s.Logger.Errorf("Error loading Levers: %v", err)
+			hasErrors = true
+		}
+	}
+
 	if hasErrors {
 		s.Logger.Infof("Loaded profile with errors")
 	} else {
@@ -187,9 +256,74 @@ func (s *xplaneService) loadDatarefProfile(fieldName string, fieldValue *pkg.Dat
 	return nil
 }
 
+func (s *xplaneService) loadDataProfile(fieldName string, fieldValue *pkg.DataProfile) error {
+	if !isAggregateSupported(fieldValue.Aggregate) {
+		return fmt.Errorf("Unsupported aggregate found: %s", fieldValue.Aggregate)
+	}
+	if fieldValue.Range != nil && (fieldValue.Range.From < 0 || fieldValue.Range.To < fieldValue.Range.From) {
+		return fmt.Errorf("Invalid index range %d..%d: %s", fieldValue.Range.From, fieldValue.Range.To, fieldName)
+	}
+	return s.loadDatarefProfile(fieldName, &fieldValue.DatarefProfile)
+}
+
+func (s *xplaneService) loadKnobProfile(fieldName string, fieldValue *pkg.KnobProfile) error {
+	if err := validateKnobLimits(fieldValue.KnobLimits); err != nil {
+		return fmt.Errorf("%s: %v", fieldName, err)
+	}
+	if fieldValue.Mach != nil {
+		if err := validateKnobLimits(fieldValue.Mach.KnobLimits); err != nil {
+			return fmt.Errorf("%s.mach: %v", fieldName, err)
+		}
+		if fieldValue.Mach.DatarefStr == "" {
+			return fmt.Errorf("%s.mach: missing dataref_str", fieldName)
+		}
+		fieldValue.Mach.Dataref.Dataref = s.getDataref(fieldValue.Mach.DatarefStr)
+	}
+	if fieldValue.Acceleration != nil {
+		if err := loadKnobAcceleration(fieldValue.Acceleration); err != nil {
+			return fmt.Errorf("%s.acceleration: %v", fieldName, err)
+		}
+	}
+	if err := loadKnobCommands(fieldValue); err != nil {
+		return fmt.Errorf("%s: %v", fieldName, err)
+	}
+	s.resolveKnobCommands(fieldName, &fieldValue.KnobCommands)
+	return s.loadDatarefProfile(fieldName, &fieldValue.DatarefProfile)
+}
+
 func (s *xplaneService) loadConditionProfile(fieldName string, fieldValue *pkg.ConditionProfile) error {
+	if fieldValue.OnDelay < 0 || fieldValue.OffDelay < 0 {
+		return fmt.Errorf("Condition delays must not be negative: %s", fieldName)
+	}
+	fieldValue.Timer = nil
+	fieldValue.ExprFailed = false
+	if fieldValue.OnDelay > 0 || fieldValue.OffDelay > 0 {
+		fieldValue.Timer = &pkg.ConditionTimer{}
+	}
+
+	if fieldValue.Expr != "" {
+		err := s.loadConditionExpr(fieldName, fieldValue)
+		if err != nil {
+			return err
+		}
+		s.Logger.Infof("-- Rules compiled successfully for: %s", fieldName)
+		return nil
+	}
+
+	if !isConditionLogicSupported(fieldValue.Condition) {
+		return fmt.Errorf("Unsupported condition found: %s", fieldValue.Condition)
+	}
+	for j := range fieldValue.Groups {
+		err := s.loadConditionProfile(fmt.Sprintf("%s.groups[%d]", fieldName, j), &fieldValue.Groups[j])
+		if err != nil {
+			return err
+		}
+	}
+
 	if fieldValue.Datarefs == nil {
-		s.Logger.Infof("---- No datarefs specified")
+		if len(fieldValue.Groups) == 0 {
+			s.Logger.Infof("---- No datarefs specified")
+		}
 		return nil
 	}
 
@@ -202,31 +336,16 @@ func (s *xplaneService) loadConditionProfile(fieldName string, fieldValue *pkg.C
 		}
 
 		dataref.Dataref = myDataref
-		datarefType := dataAccess.GetDataRefTypes(myDataref)
+		datarefType := myDataref.kind
 
 		if dataref.Operator != "" {
-			if !isOperatorSupported(dataref.Operator) {
+			if !isOperatorSupported(dataref.Operator) && !isStringOperatorSupported(dataref.Operator) {
 				return fmt.Errorf("Unsupported operator found: %s", dataref.Operator)
 			}
 
-			threshold := float32(0)
-			if dataref.Threshold != nil {
-				threshold = *dataref.Threshold
-			}
-
-			var code string
-			if datarefType&dataAccess.TypeFloat > 0 {
-				code = fmt.Sprintf("GetFloatData(myDataref) %s %f", dataref.Operator, threshold)
-			} else if datarefType&dataAccess.TypeInt > 0 {
-				code = fmt.Sprintf("GetIntData(myDataref) %s %d", dataref.Operator, int(threshold))
-			} else if datarefType&dataAccess.TypeFloatArray > 0 {
-				code = fmt.Sprintf("GetFloatArrayData(myDataref)[%d] %s %f", dataref.Index, dataref.Operator, threshold)
-			} else if datarefType&dataAccess.TypeIntArray > 0 {
-				code = fmt.Sprintf("GetIntArrayData(myDataref)[%d] %s %d", dataref.Index, dataref.Operator, int(threshold))
-			} else if datarefType&dataAccess.TypeDouble > 0 {
-				code = fmt.Sprintf("GetDoubleData(myDataref) %s %f", dataref.Operator, threshold)
-			} else {
-				return fmt.Errorf("Dataref type not supported: %v", datarefType)
+			code, err := conditionCode(datarefType, dataref)
+			if err != nil {
+				return err
 			}
 
 			s.Logger.Infof("---- Compiling expression: %s - %s[%d]: %s", code, fieldName, j, dataref.DatarefStr)
@@ -236,7 +355,8 @@ func (s *xplaneService) loadConditionProfile(fieldName string, fieldValue *pkg.C
 				"GetFloatArrayData": dataAccess.GetFloatArrayData,
 				"GetIntArrayData":   dataAccess.GetIntArrayData,
 				"GetDoubleData":     dataAccess.GetDoubleData,
-				"myDataref":         myDataref,
+				"GetString":         dataAccess.GetString,
+				"myDataref":         myDataref.ref,
 			}
 			program, err := expr.Compile(code, expr.Env(env))
 			if err != nil {
@@ -244,6 +364,20 @@ func (s *xplaneService) loadConditionProfile(fieldName string, fieldValue *pkg.C
 			}
 			dataref.Expr = program
 			dataref.Env = env
+
+			dataref.HoldExpr = nil
+			if dataref.Hysteresis != 0 {
+				code, err = holdConditionCode(datarefType, dataref)
+				if err != nil {
+					return err
+				}
+				s.Logger.Infof("---- Compiling hysteresis expression: %s - %s[%d]: %s", code, fieldName, j, dataref.DatarefStr)
+				program, err = expr.Compile(code, expr.Env(env))
+				if err != nil {
+					return fmt.Errorf("Error compiling expression: %v", err)
+				}
+				dataref.HoldExpr = program
+			}
 		} else {
 			return fmt.Errorf("Condition missing operator: %s", fieldName)
 		}
@@ -253,25 +387,242 @@ func (s *xplaneService) loadConditionProfile(fieldName string, fieldValue *pkg.C
 	return nil
 }
 
+// conditionCode builds the expression that compares a dataref, or the
+// elements of an array dataref, with its threshold.
+func conditionCode(datarefType dataAccess.DataRefType, dataref *pkg.DatarefCondition) (string, error) {
+	if isStringOperatorSupported(dataref.Operator) {
+		return stringConditionCode(datarefType, dataref)
+	}
+
+	threshold := float32(0)
+	if dataref.Threshold != nil {
+		threshold = *dataref.Threshold
+	}
+
+	var array, compare string
+	if datarefType&dataAccess.TypeFloatArray > 0 {
+		array = "GetFloatArrayData(myDataref)"
+		compare = fmt.Sprintf("%s %f", dataref.Operator, threshold)
+	} else if datarefType&dataAccess.TypeIntArray > 0 {
+		array = "GetIntArrayData(myDataref)"
+		compare = fmt.Sprintf("%s %d", dataref.Operator, int(threshold))
+	} else {
+		if dataref.Match != "" {
+			return "", fmt.Errorf("Match needs an array dataref: %s", dataref.DatarefStr)
+		}
+		if datarefType&dataAccess.TypeFloat > 0 {
+			return fmt.Sprintf("GetFloatData(myDataref) %s %f", dataref.Operator, threshold), nil
+		} else if datarefType&dataAccess.TypeInt > 0 {
+			return fmt.Sprintf("GetIntData(myDataref) %s %d", dataref.Operator, int(threshold)), nil
+		} else if datarefType&dataAccess.TypeDouble > 0 {
+			return fmt.Sprintf("GetDoubleData(myDataref) %s %f", dataref.Operator, threshold), nil
+		} else if datarefType&dataAccess.TypeData > 0 {
+			return "", fmt.Errorf("Operator %s does not work on string dataref: %s", dataref.Operator, dataref.DatarefStr)
+		}
+		return "", fmt.Errorf("Dataref type not supported: %v", datarefType)
+	}
+
+	if dataref.Match == "" {
+		return fmt.Sprintf("%s[%d] %s", array, dataref.Index, compare), nil
+	}
+	if dataref.Range != nil {
+		if dataref.Range.From < 0 || dataref.Range.To < dataref.Range.From {
+			return "", fmt.Errorf("Invalid index range %d..%d: %s", dataref.Range.From, dataref.Range.To, dataref.DatarefStr)
+		}
+		array = fmt.Sprintf("%s[%d:%d]", array, dataref.Range.From, dataref.Range.To+1)
+	}
+
+	switch dataref.Match {
+	case "any":
+		return fmt.Sprintf("any(%s, # %s)", array, compare), nil
+	case "all":
+		// a range past the end of the array leaves no elements, which must
+		// not count as all of them passing
+		return fmt.Sprintf("len(%s) > 0 && all(%s, # %s)", array, array, compare), nil
+	case "count":
+		if dataref.Count <= 0 {
+			return "", fmt.Errorf("Match count needs a count above 0: %s", dataref.DatarefStr)
+		}
+		return fmt.Sprintf("count(%s, # %s) >= %d", array, compare, dataref.Count), nil
+	default:
+		return "", fmt.Errorf("Unsupported match found: %s", dataref.Match)
+	}
+}
+
+// holdConditionCode builds the expression used while a check with hysteresis
+// passes. Its threshold is moved back by the hysteresis band, so the value
+// has to leave the band before the check fails.
+func holdConditionCode(datarefType dataAccess.DataRefType, dataref *pkg.DatarefCondition) (string, error) {
+	if dataref.Hysteresis < 0 {
+		return "", fmt.Errorf("Hysteresis must not be negative: %s", dataref.DatarefStr)
+	}
+
+	threshold := float32(0)
+	if dataref.Threshold != nil {
+		threshold = *dataref.Threshold
+	}
+	switch dataref.Operator {
+	case "<", "<=":
+		threshold += dataref.Hysteresis
+	case ">", ">=":
+		threshold -= dataref.Hysteresis
+	default:
+		return "", fmt.Errorf("Hysteresis needs a <, <=, > or >= operator: %s", dataref.DatarefStr)
+	}
+
+	held := *dataref
+	held.Threshold = &threshold
+	return conditionCode(datarefType, &held)
+}
+
+// stringConditionCode builds the expression that compares a string dataref
+// with its text.
+func stringConditionCode(datarefType dataAccess.DataRefType, dataref *pkg.DatarefCondition) (string, error) {
+	if datarefType&dataAccess.TypeData == 0 {
+		return "", fmt.Errorf("Operator %s needs a string dataref: %s", dataref.Operator, dataref.DatarefStr)
+	}
+	if dataref.Match != "" {
+		return "", fmt.Errorf("Match needs an array dataref: %s", dataref.DatarefStr)
+	}
+
+	text := strconv.Quote(dataref.Text)
+	switch dataref.Operator {
+	case "equals":
+		return fmt.Sprintf("GetString(myDataref) == %s", text), nil
+	case "contains":
+		return fmt.Sprintf("GetString(myDataref) contains %s", text), nil
+	case "prefix":
+		return fmt.Sprintf("GetString(myDataref) startsWith %s", text), nil
+	default:
+		return fmt.Sprintf("GetString(myDataref) matches %s", text), nil
+	}
+}
+
 func (s *xplaneService) loadLedProfile(fieldName string, fieldValue *pkg.LEDProfile) error {
 	err := s.loadConditionProfile(fieldName, &fieldValue.ConditionProfile)
 
 	fieldValue.On, fieldValue.Off = s.assignOnAndOffFuncs(fieldName)
-	return err
+	if err != nil {
+		return err
+	}
+
+	pattern, err := ledPattern(fieldValue.Pattern)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fieldName, err)
+	}
+	s.setLedPattern(fieldName, pattern)
+	return nil
+}
+
+func (s *xplaneService) loadGearLedProfile(fieldName string, fieldValue *pkg.GearLEDProfile) error {
+	err := s.loadLedProfile(fieldName, &fieldValue.LEDProfile)
+	if err != nil {
+		return err
+	}
+	err = s.loadConditionProfile(fieldName+".unsafe", &fieldValue.Unsafe)
+	if err != nil {
+		return err
+	}
+
+	// legs without their own dataref read the first gear dataref
+	var fallback pkg.Dataref
+	if len(fieldValue.Datarefs) > 0 {
+		fallback.DatarefStr = fieldValue.Datarefs[0].DatarefStr
+		fallback.Dataref = fieldValue.Datarefs[0].Dataref
+	}
+	fieldValue.Nose = s.loadGearLeg(fieldValue.Nose, fallback, 0)
+	fieldValue.Left = s.loadGearLeg(fieldValue.Left, fallback, 1)
+	fieldValue.Right = s.loadGearLeg(fieldValue.Right, fallback, 2)
+	return nil
+}
+
+func (s *xplaneService) loadGearLeg(leg *pkg.Dataref, fallback pkg.Dataref, defaultIndex int) *pkg.Dataref {
+	if leg == nil {
+		res := fallback
+		res.Index = defaultIndex
+		return &res
+	}
+	if leg.DatarefStr == "" {
+		leg.DatarefStr = fallback.DatarefStr
+		leg.Dataref = fallback.Dataref
+	} else {
+		leg.Dataref = s.getDataref(leg.DatarefStr)
+	}
+	return leg
+}
+
+func (s *xplaneService) loadActionProfile(fieldName string, fieldValue *pkg.ActionProfile) error {
+	for j, cmd := range fieldValue.Commands {
+		if err := validateQueuedCommand(cmd); err != nil {
+			return fmt.Errorf("Action command[%d] in %s: %v", j, fieldName, err)
+		}
+	}
+	for j := range fieldValue.Datarefs {
+		dataref := &fieldValue.Datarefs[j]
+		if dataref.DatarefStr == "" {
+			return fmt.Errorf("Action dataref[%d] missing dataref_str: %s", j, fieldName)
+		}
+		dataref.Dataref.Dataref = s.getDataref(dataref.DatarefStr)
+	}
+	if fieldValue.Macro != "" {
+		if err := s.checkMacroRef(fieldValue.Macro); err != nil {
+			return fmt.Errorf("%s: %v", fieldName, err)
+		}
+	}
+	return nil
+}
+
+func (s *xplaneService) loadSwitchProfile(fieldName string, fieldValue *pkg.SwitchProfile) error {
+	err := s.loadActionProfile(fieldName+".up", &fieldValue.Up)
+	if err != nil {
+		return err
+	}
+	return s.loadActionProfile(fieldName+".down", &fieldValue.Down)
+}
+
+func (s *xplaneService) loadGearLeverProfile(fieldValue *pkg.GearLeverProfile) error {
+	err := s.loadActionProfile("gear_lever.up", &fieldValue.Up)
+	if err != nil {
+		return err
+	}
+	return s.loadActionProfile("gear_lever.down", &fieldValue.Down)
+}
+
+func (s *xplaneService) loadFlapLeverProfile(fieldValue *pkg.FlapLeverProfile) error {
+	err := s.loadActionProfile("flap_lever.up", &fieldValue.Up)
+	if err != nil {
+		return err
+	}
+	err = s.loadActionProfile("flap_lever.down", &fieldValue.Down)
+	if err != nil {
+		return err
+	}
+
+	if len(fieldValue.Detents) == 0 {
+		return nil
+	}
+	if fieldValue.DatarefStr == "" {
+		return fmt.Errorf("Flap lever detents need a dataref_str")
+	}
+	sort.Slice(fieldValue.Detents, func(i, j int) bool {
+		return fieldValue.Detents[i] < fieldValue.Detents[j]
+	})
+	fieldValue.Dataref.Dataref = s.getDataref(fieldValue.DatarefStr)
+	return nil
 }
 
 func (s *xplaneService) loadProfileElement(fieldName string, value interface{}) (interface{}, error) {
 	dataProfileValue, ok := value.(pkg.DataProfile)
 	if ok {
 		s.Logger.Infof("-- Loading Data: %s", fieldName)
-		err := s.loadDatarefProfile(fieldName, &dataProfileValue.DatarefProfile)
+		err := s.loadDataProfile(fieldName, &dataProfileValue)
 		return dataProfileValue, err
 	}
 
 	knobProfileValue, ok := value.(pkg.KnobProfile)
 	if ok {
 		s.Logger.Infof("-- Loading Knob: %s", fieldName)
-		err := s.loadDatarefProfile(fieldName, &knobProfileValue.DatarefProfile)
+		err := s.loadKnobProfile(fieldName, &knobProfileValue)
 		return knobProfileValue, err
 	}
 
@@ -282,6 +633,13 @@ func (s *xplaneService) loadProfileElement(fieldName string, value interface{})
 		return conditionProfileValue, err
 	}
 
+	gearLedProfileValue, ok := value.(pkg.GearLEDProfile)
+	if ok {
+		s.Logger.Infof("-- Loading Gear LED: %s", fieldName)
+		err := s.loadGearLedProfile(fieldName, &gearLedProfileValue)
+		return gearLedProfileValue, err
+	}
+
 	ledProfileValue, ok := value.(pkg.LEDProfile)
 	if ok {
 		s.Logger.Infof("-- Loading LED: %s", fieldName)
@@ -289,5 +647,19 @@ func (s *xplaneService) loadProfileElement(fieldName string, value interface{})
 		return ledProfileValue, err
 	}
 
+	buttonProfileValue, ok := value.(pkg.ButtonProfile)
+	if ok {
+		s.Logger.Infof("-- Loading Button: %s", fieldName)
+		err := s.loadButtonProfile(fieldName, &buttonProfileValue)
+		return buttonProfileValue, err
+	}
+
+	switchProfileValue, ok := value.(pkg.SwitchProfile)
+	if ok {
+		s.Logger.Infof("-- Loading Switch: %s", fieldName)
+		err := s.loadSwitchProfile(fieldName, &switchProfileValue)
+		return switchProfileValue, err
+	}
+
 	return value, fmt.Errorf("Field %s is not of a known type", fieldName)
 }
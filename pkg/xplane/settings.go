@@ -0,0 +1,50 @@
+package xplane
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settingsFileName is kept in the plugin folder. It is not part of a release,
+// so updates leave it alone.
+const settingsFileName = "settings.yaml"
+
+// pluginSettings are the plugin menu choices kept across sim restarts.
+type pluginSettings struct {
+	DirectInput bool `yaml:"direct_input"`
+}
+
+// loadPluginSettings reads the settings saved in pluginPath. A missing file
+// gives the defaults.
+func loadPluginSettings(pluginPath string) (pluginSettings, error) {
+	var settings pluginSettings
+	content, err := os.ReadFile(filepath.Join(pluginPath, settingsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+	err = yaml.Unmarshal(content, &settings)
+	return settings, err
+}
+
+func savePluginSettings(pluginPath string, settings pluginSettings) error {
+	content, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(pluginPath, settingsFileName), content, 0644)
+}
+
+// saveSettings writes the current menu choices.
+func (s *xplaneService) saveSettings() {
+	err := savePluginSettings(s.pluginPath, pluginSettings{DirectInput: s.directInput})
+	if err != nil {
+		s.Logger.Errorf("Failed to save settings: %v", err)
+	}
+}
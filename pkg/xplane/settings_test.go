@@ -0,0 +1,26 @@
+package xplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginSettingsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	settings, err := loadPluginSettings(dir)
+	assert.NoError(t, err)
+	assert.False(t, settings.DirectInput)
+
+	assert.NoError(t, savePluginSettings(dir, pluginSettings{DirectInput: true}))
+	settings, err = loadPluginSettings(dir)
+	assert.NoError(t, err)
+	assert.True(t, settings.DirectInput)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, settingsFileName), []byte("direct_input: ["), 0644))
+	_, err = loadPluginSettings(dir)
+	assert.Error(t, err)
+}
@@ -1,70 +1,157 @@
 package xplane
 
 import (
+	"fmt"
 	"reflect"
+	"slices"
+	"time"
 
 	"github.com/expr-lang/expr"
 	"github.com/x-z7a/zoal-honeycomb/pkg"
 	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
-	"github.com/xairline/goplane/xplm/dataAccess"
 )
 
+const (
+	defaultBlinkHz      = 2.0
+	maxBlinkHz          = 10.0
+	defaultFlashSeconds = 3.0
+)
+
+// profileLeds maps the LED fields of pkg.Leds that drive a single LED.
+var profileLeds = map[string]honeycomb.LED{
+	"APR":                honeycomb.LED_APR,
+	"ALT":                honeycomb.LED_ALT,
+	"VS":                 honeycomb.LED_VS,
+	"HDG":                honeycomb.LED_HEADING,
+	"NAV":                honeycomb.LED_NAV,
+	"REV":                honeycomb.LED_REV,
+	"IAS":                honeycomb.LED_IAS,
+	"AP":                 honeycomb.LED_AP,
+	"MASTER_WARN":        honeycomb.LED_MASTER_WARNING,
+	"MASTER_CAUTION":     honeycomb.LED_MASTER_CAUTION,
+	"FIRE":               honeycomb.LED_ENGINE_FIRE,
+	"VOLT_LOW":           honeycomb.LED_LOW_VOLTS,
+	"OIL_LOW_PRESSURE":   honeycomb.LED_LOW_OIL_PRESS,
+	"FUEL_LOW_PRESSURE":  honeycomb.LED_LOW_FUEL_PRESS,
+	"ANTI_ICE":           honeycomb.LED_ANTI_ICE,
+	"ENG_STARTER":        honeycomb.LED_STARTER,
+	"APU":                honeycomb.LED_APU,
+	"VACUUM":             honeycomb.LED_VACUUM,
+	"HYDRO_LOW_PRESSURE": honeycomb.LED_LOW_HYD_PRESS,
+	"PARKING_BRAKE":      honeycomb.LED_PARKING_BRAKE,
+	"DOORS":              honeycomb.LED_DOOR,
+	"AUX_FUEL_PUMP":      honeycomb.LED_FUEL_PUMP,
+}
+
+// profileLedTargets returns the LEDs driven by an LED field of pkg.Leds.
+func profileLedTargets(name string) []honeycomb.LED {
+	if name == "GEAR" {
+		// patterns on the gear apply to the in-transit lights
+		return []honeycomb.LED{honeycomb.LED_LEFT_GEAR_RED, honeycomb.LED_NOSE_GEAR_RED, honeycomb.LED_RIGHT_GEAR_RED}
+	}
+	if led, ok := profileLeds[name]; ok {
+		return []honeycomb.LED{led}
+	}
+	return nil
+}
+
 func (s *xplaneService) assignOnAndOffFuncs(name string) (func(), func()) {
 	switch name {
-	case "APR":
-		return honeycomb.OnLEDAPR, honeycomb.OffLEDAPR
-	case "ALT":
-		return honeycomb.OnLEDAlt, honeycomb.OffLEDAlt
-	case "VS":
-		return honeycomb.OnLEDVS, honeycomb.OffLEDVS
-	case "HDG":
-		return honeycomb.OnLEDHeading, honeycomb.OffLEDHeading
-	case "NAV":
-		return honeycomb.OnLEDNav, honeycomb.OffLEDNav
-	case "REV":
-		return honeycomb.OnLEDREV, honeycomb.OffLEDREV
-	case "IAS":
-		return honeycomb.OnLEDIAS, honeycomb.OffLEDIAS
-	case "AP":
-		return honeycomb.OnLEDAP, honeycomb.OffLEDAP
 	case "BUS_VOLTAGE":
 		return func() {
 			return
-		}, honeycomb.AllOff
+		}, s.allLedsOff
 	case "GEAR":
-		return honeycomb.OnLedGearGreen, honeycomb.OnLedGearRed
-	case "MASTER_WARN":
-		return honeycomb.OnLEDMasterWarning, honeycomb.OffLEDMasterWarning
-	case "MASTER_CAUTION":
-		return honeycomb.OnLEDMasterCaution, honeycomb.OffLEDMasterCaution
-	case "FIRE":
-		return honeycomb.OnLEDEngineFire, honeycomb.OffLEDEngineFire
-	case "VOLT_LOW":
-		return honeycomb.OnLEDLowVolts, honeycomb.OffLEDLowVolts
-	case "OIL_LOW_PRESSURE":
-		return honeycomb.OnLEDLowOilPress, honeycomb.OffLEDLowOilPress
-	case "FUEL_LOW_PRESSURE":
-		return honeycomb.OnLEDLowFuelPress, honeycomb.OffLEDLowFuelPress
-	case "ANTI_ICE":
-		return honeycomb.OnLEDAntiIce, honeycomb.OffLEDAntiIce
-	case "ENG_STARTER":
-		return honeycomb.OnLEDStarter, honeycomb.OffLEDStarter
-	case "APU":
-		return honeycomb.OnLEDApu, honeycomb.OffLEDApu
-	case "VACUUM":
-		return honeycomb.OnLEDVacuum, honeycomb.OffLEDVacuum
-	case "HYDRO_LOW_PRESSURE":
-		return honeycomb.OnLEDLowHydPress, honeycomb.OffLEDLowHydPress
-	case "PARKING_BRAKE":
-		return honeycomb.OnLEDParkingBrake, honeycomb.OffLEDParkingBrake
-	case "DOORS":
-		return honeycomb.OnLEDDoor, honeycomb.OffLEDDoor
-	case "AUX_FUEL_PUMP":
-		return honeycomb.OnLEDFuelPump, honeycomb.OffLEDFuelPump
+		return func() {
+				s.setGearLeds(true, false)
+			}, func() {
+				s.setGearLeds(false, true)
+			}
+	}
+
+	if led, ok := profileLeds[name]; ok {
+		return s.ledOnAndOff(led)
+	}
+	s.Logger.Warningf("No on/off functions found for: %s", name)
+	return nil, nil
+}
+
+// ledOnAndOff returns funcs that switch a single LED in the state being built
+// by the current flight loop.
+func (s *xplaneService) ledOnAndOff(led honeycomb.LED) (func(), func()) {
+	return func() {
+			s.leds.Set(led, true)
+		}, func() {
+			s.leds.Set(led, false)
+		}
+}
+
+func (s *xplaneService) setGearLeds(green bool, red bool) {
+	s.leds.Set(honeycomb.LED_LEFT_GEAR_GREEN, green)
+	s.leds.Set(honeycomb.LED_LEFT_GEAR_RED, red)
+	s.leds.Set(honeycomb.LED_NOSE_GEAR_GREEN, green)
+	s.leds.Set(honeycomb.LED_NOSE_GEAR_RED, red)
+	s.leds.Set(honeycomb.LED_RIGHT_GEAR_GREEN, green)
+	s.leds.Set(honeycomb.LED_RIGHT_GEAR_RED, red)
+}
+
+// ledPattern converts a profile pattern to the pattern shown by the panel.
+func ledPattern(pattern *pkg.LEDPatternProfile) (honeycomb.LEDPattern, error) {
+	if pattern == nil {
+		return honeycomb.LEDPattern{}, nil
+	}
+
+	hz := float64(pattern.Hz)
+	if hz == 0 {
+		hz = defaultBlinkHz
+	}
+	if hz < 0 || hz > maxBlinkHz {
+		return honeycomb.LEDPattern{}, fmt.Errorf("Blink rate must be between 0 and %v Hz, got %v", maxBlinkHz, hz)
+	}
+
+	switch pattern.Mode {
+	case "", "steady":
+		return honeycomb.LEDPattern{}, nil
+	case "blink":
+		return honeycomb.LEDPattern{BlinkHz: hz}, nil
+	case "flash":
+		duration := float64(pattern.Duration)
+		if duration == 0 {
+			duration = defaultFlashSeconds
+		}
+		if duration < 0 {
+			return honeycomb.LEDPattern{}, fmt.Errorf("Flash duration must not be negative, got %v", duration)
+		}
+		return honeycomb.LEDPattern{
+			BlinkHz: hz,
+			Flash:   time.Duration(duration * float64(time.Second)),
+		}, nil
 	default:
-		s.Logger.Warningf("No on/off functions found for: %s", name)
-		return nil, nil
+		return honeycomb.LEDPattern{}, fmt.Errorf("Unsupported LED pattern mode: %s", pattern.Mode)
+	}
+}
+
+// setLedPattern hands the pattern of an LED field of pkg.Leds to the panel.
+func (s *xplaneService) setLedPattern(name string, pattern honeycomb.LEDPattern) {
+	if s.BravoService == nil {
+		return
+	}
+	for _, led := range profileLedTargets(name) {
+		s.BravoService.Panel().SetPattern(led, pattern)
+	}
+}
+
+func (s *xplaneService) allLedsOff() {
+	s.leds = 0
+	s.applyLeds()
+}
+
+// applyLeds hands the LED state built by the flight loop to the Bravo.
+func (s *xplaneService) applyLeds() {
+	if s.BravoService == nil {
+		return
 	}
+	s.BravoService.Panel().Apply(s.leds)
 }
 
 func rangeStruct(s interface{}, modify func(name string, value interface{}) (interface{}, error)) error {
@@ -89,11 +176,10 @@ func rangeStruct(s interface{}, modify func(name string, value interface{}) (int
 	return nil
 }
 
-func (s *xplaneService) getDataref(datarefStr string) dataAccess.DataRef {
+func (s *xplaneService) getDataref(datarefStr string) *datarefHandle {
 	s.Logger.Infof("---- Finding dataref: %s", datarefStr)
-	// Get a pointer to the actual element
-	myDataref, found := dataAccess.FindDataRef(datarefStr)
-	if !found {
+	myDataref := s.datarefs.lookup(datarefStr)
+	if myDataref == nil {
 		s.Logger.Errorf("Dataref not found: %s", datarefStr)
 		return nil
 	}
@@ -112,36 +198,124 @@ func isOperatorSupported(operator string) bool {
 		operator == "!="
 }
 
+// Check whether the given operator compares a string dataref with text.
+func isStringOperatorSupported(operator string) bool {
+	return operator == "equals" ||
+		operator == "contains" ||
+		operator == "prefix" ||
+		operator == "regex"
+}
+
+// Check whether the given condition logic is supported. An empty condition
+// means "all".
+func isConditionLogicSupported(condition string) bool {
+	return condition == "" ||
+		condition == "all" ||
+		condition == "any" ||
+		condition == "none"
+}
+
 // Evaluate a condition
 // Returns:
 // 1. The result of the condition evaluation
 // 2. Whether the condition was valid (if false then it should be ignored)
 func (s *xplaneService) evaluateCondition(condition *pkg.ConditionProfile) (bool, bool) {
-	var valid = false
-	var result bool
-	if condition.Condition == "any" {
-		result = false
-	} else {
-		result = true
+	result, valid := s.evaluateConditionRules(condition)
+	if !valid || condition.Timer == nil {
+		return result, valid
 	}
-	for _, dataref := range condition.Datarefs {
+	return delayedResult(condition.Timer, result, s.globalTime, condition.OnDelay, condition.OffDelay), true
+}
+
+// evaluateConditionRules evaluates the datarefs, groups or expression of a
+// condition right now, without its delays.
+func (s *xplaneService) evaluateConditionRules(condition *pkg.ConditionProfile) (bool, bool) {
+	if condition.Program != nil {
+		output, err := s.runConditionExpr(condition)
+		if err != nil {
+			// logged once, the condition stays false until the next profile load
+			if !condition.ExprFailed {
+				condition.ExprFailed = true
+				s.Logger.Errorf("Error running expression %q: %v", condition.Expr, err)
+			}
+			return false, true
+		}
+		return output, true
+	}
+
+	passed, total := 0, 0
+	for i := range condition.Datarefs {
+		dataref := &condition.Datarefs[i]
 		if dataref.Expr == nil {
 			continue
 		}
-		output, err := expr.Run(dataref.Expr, dataref.Env)
+		program := dataref.Expr
+		if dataref.Passing && dataref.HoldExpr != nil {
+			program = dataref.HoldExpr
+		}
+		output, err := expr.Run(program, dataref.Env)
 		if err != nil {
 			s.Logger.Errorf("Error running expression: %v", err)
 			continue
 		}
-		if condition.Condition == "any" {
-			result = result || output.(bool)
-		} else {
-			// all or nothing (single value)
-			result = result && output.(bool)
+		dataref.Passing = output.(bool)
+		if dataref.Passing {
+			passed++
 		}
-		valid = true
+		total++
+	}
+	for i := range condition.Groups {
+		output, ok := s.evaluateCondition(&condition.Groups[i])
+		if !ok {
+			continue
+		}
+		if output {
+			passed++
+		}
+		total++
+	}
+	if total == 0 {
+		return false, false
+	}
+	return conditionResult(condition.Condition, passed, total), true
+}
+
+// delayedResult returns the result of a condition whose rules evaluate to
+// result at now. A new result is only used once it lasted onDelay seconds
+// when turning on or offDelay seconds when turning off.
+func delayedResult(timer *pkg.ConditionTimer, result bool, now float64, onDelay float32, offDelay float32) bool {
+	if result == timer.Result {
+		timer.Pending = false
+		return result
+	}
+	if !timer.Pending {
+		timer.Pending = true
+		timer.Since = now
+	}
+
+	delay := offDelay
+	if result {
+		delay = onDelay
+	}
+	if now-timer.Since >= float64(delay) {
+		timer.Result = result
+		timer.Pending = false
+	}
+	return timer.Result
+}
+
+// conditionResult combines the results of the checks in a condition, passed
+// of total checks were true.
+func conditionResult(condition string, passed int, total int) bool {
+	switch condition {
+	case "any":
+		return passed > 0
+	case "none":
+		return passed == 0
+	default:
+		// all or nothing (single value)
+		return passed == total
 	}
-	return result, valid
 }
 
 // Extract a value from the given data profile
@@ -150,30 +324,115 @@ func (s *xplaneService) evaluateCondition(condition *pkg.ConditionProfile) (bool
 // 2. Whether a value was found or not
 func (s *xplaneService) dataValue(bp *pkg.DataProfile) (float64, bool) {
 	if len(bp.Datarefs) > 0 {
-		// TODO support something like "condition" that can aggregate multiple datarefs or array datarefs
-		// e.g. "max" or "min" or "sum" or "avg"
-		myDataref := bp.Datarefs[0]
-		if myDataref.Dataref == nil {
-			return 0.0, false
-		}
-		datarefType := dataAccess.GetDataRefTypes(myDataref.Dataref.(dataAccess.DataRef))
-		if datarefType&dataAccess.TypeFloat > 0 {
-			return float64(dataAccess.GetFloatData(myDataref.Dataref.(dataAccess.DataRef))), true
-		} else if datarefType&dataAccess.TypeInt > 0 {
-			return float64(dataAccess.GetIntData(myDataref.Dataref.(dataAccess.DataRef))), true
-		} else if datarefType&dataAccess.TypeFloatArray > 0 {
-			return float64(dataAccess.GetFloatArrayData(myDataref.Dataref.(dataAccess.DataRef))[0]), true
-		} else if datarefType&dataAccess.TypeIntArray > 0 {
-			return float64(dataAccess.GetIntArrayData(myDataref.Dataref.(dataAccess.DataRef))[0]), true
-		} else if datarefType&dataAccess.TypeDouble > 0 {
-			return dataAccess.GetDoubleData(myDataref.Dataref.(dataAccess.DataRef)), true
+		var value float64
+		if bp.Aggregate == "" {
+			myDataref := &bp.Datarefs[0]
+			values, ok := s.readDataValues(myDataref)
+			if !ok {
+				return 0.0, false
+			}
+			if myDataref.Index >= len(values) {
+				s.Logger.Errorf("Index %d out of range for dataref: %s", myDataref.Index, myDataref.DatarefStr)
+				return 0.0, false
+			}
+			value = values[myDataref.Index]
 		} else {
-			s.Logger.Errorf("Dataref type not supported: %v", datarefType)
-			return 0.0, false
+			var values []float64
+			for i := range bp.Datarefs {
+				elements, ok := s.readDataValues(&bp.Datarefs[i])
+				if !ok {
+					continue
+				}
+				values = append(values, indexRange(elements, bp.Range)...)
+			}
+			var ok bool
+			value, ok = aggregateValues(bp.Aggregate, values)
+			if !ok {
+				return 0.0, false
+			}
+		}
+
+		if bp.Scale != nil {
+			value *= float64(*bp.Scale)
 		}
+		return value + float64(bp.Offset), true
 	} else if bp.Value != nil {
 		return float64(*bp.Value), true
 	} else {
 		return 0.0, false
 	}
 }
+
+// readDataValues returns the value of a dataref, or every element of an array
+// dataref. String datarefs are read as a number.
+func (s *xplaneService) readDataValues(myDataref *pkg.Dataref) ([]float64, bool) {
+	handle := datarefOf(myDataref.Dataref)
+	if handle == nil {
+		return nil, false
+	}
+	values, err := handle.values()
+	if err != nil {
+		s.Logger.Errorf("%v", err)
+		return nil, false
+	}
+	return values, true
+}
+
+// indexRange returns the values in r, or all of them if r is nil. Indexes
+// past the end are ignored.
+func indexRange(values []float64, r *pkg.IndexRange) []float64 {
+	if r == nil {
+		return values
+	}
+	from, to := r.From, r.To+1
+	if to > len(values) {
+		to = len(values)
+	}
+	if from >= to {
+		return nil
+	}
+	return values[from:to]
+}
+
+// Check whether the given aggregate is supported by aggregateValues.
+func isAggregateSupported(aggregate string) bool {
+	return aggregate == "" ||
+		aggregate == "min" ||
+		aggregate == "max" ||
+		aggregate == "sum" ||
+		aggregate == "avg" ||
+		aggregate == "first_non_zero"
+}
+
+// aggregateValues combines values into one. It returns false if there is
+// nothing to combine.
+func aggregateValues(aggregate string, values []float64) (float64, bool) {
+	if len(values) == 0 {
+		return 0.0, false
+	}
+
+	switch aggregate {
+	case "min":
+		return slices.Min(values), true
+	case "max":
+		return slices.Max(values), true
+	case "sum", "avg":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		if aggregate == "avg" {
+			return sum / float64(len(values)), true
+		}
+		return sum, true
+	case "first_non_zero":
+		for _, v := range values {
+			if v != 0 {
+				return v, true
+			}
+		}
+		return 0.0, true
+	default:
+		return values[0], true
+	}
+}
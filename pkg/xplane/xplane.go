@@ -7,7 +7,10 @@ package xplane
 import "C"
 import (
 	"context"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,10 +24,9 @@ import (
 
 var VERSION = "development"
 
-type commandState struct {
-	startTime float64
-	active    bool
-}
+// maxReportRateEnvVar sets how many LED reports per second are sent to the
+// Bravo.
+const maxReportRateEnvVar = "ZOAL_BRAVO_MAX_REPORT_RATE"
 
 type XplaneService interface {
 	// init
@@ -44,9 +46,11 @@ type xplaneService struct {
 	BravoService    honeycomb.BravoService
 	Logger          pkg.Logger
 	debug           bool
+	directInput     bool
 	pluginPath      string
 	myMenuId        menus.MenuID
 	myMenuItemIndex int
+	inputMenuIndex  int
 	profile         *pkg.Profile
 	apSelector      string
 	lastKnobTime    time.Time
@@ -54,7 +58,12 @@ type xplaneService struct {
 	lastClickTime   map[string]time.Time // Map to track the last click time for each button
 	mutex           sync.Mutex
 	clickTimers     map[string]*time.Timer
-	cmdEventQueue   []string
+	pressTimes      map[string]time.Time
+	heldButtons     map[string][]string
+	cmdEventQueue   []queuedCommand
+	actionQueue     [][]pkg.ButtonAction
+	macros          map[string][]pkg.ButtonAction
+	macroRuns       []*macroRun
 	cmdEventQueueMu sync.Mutex
 	cancelFunc      context.CancelFunc
 	commandStates   map[string]*commandState
@@ -63,6 +72,24 @@ type xplaneService struct {
 	tolissTrimMu    sync.Mutex
 	tolissTrimCmd   string
 	tolissTrimInput time.Time
+	leds            honeycomb.LEDState
+	datarefs        datarefRegistry
+	levers          leverState
+}
+
+// bravoOptions returns the Bravo settings taken from the environment.
+func bravoOptions(logger pkg.Logger) []honeycomb.BravoOption {
+	var opts []honeycomb.BravoOption
+	if raw := strings.TrimSpace(os.Getenv(maxReportRateEnvVar)); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate <= 0 {
+			logger.Errorf("%s must be a number above 0, got %q", maxReportRateEnvVar, raw)
+		} else {
+			logger.Infof("Bravo max report rate: %v per second", rate)
+			opts = append(opts, honeycomb.WithMaxReportRate(rate))
+		}
+	}
+	return opts
 }
 
 var xplaneSvcLock = &sync.Mutex{}
@@ -86,13 +113,15 @@ func NewXplaneService(
 
 		xplaneSvc := &xplaneService{
 			Plugin:        extra.NewPlugin("zoal honeycomb - "+VERSION, "com.github.x-z7a.zoal-honeycomb", "honeycomb bridge"),
-			BravoService:  honeycomb.NewBravoService(logger),
+			BravoService:  honeycomb.NewBravoService(logger, bravoOptions(logger)...),
 			Logger:        logger,
 			pluginPath:    pluginPath,
 			profile:       nil,
 			apSelector:    "",
 			lastClickTime: make(map[string]time.Time),
 			clickTimers:   make(map[string]*time.Timer),
+			pressTimes:    make(map[string]time.Time),
+			heldButtons:   make(map[string][]string),
 			cancelFunc:    cancelFunc,
 			commandStates: make(map[string]*commandState),
 			globalTime:    0.0,
@@ -108,6 +137,6 @@ func (s *xplaneService) messageHandler(message plugins.Message) {
 		s.Logger.Info("Plane loaded")
 		s.resetTolissTrimCommand()
 		s.profile = nil
-		honeycomb.AllOff()
+		s.allLedsOff()
 	}
 }
@@ -5,9 +5,14 @@ import (
 	"path"
 	"runtime"
 	"testing"
+	"time"
 
+	"github.com/expr-lang/expr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/x-z7a/zoal-honeycomb/pkg"
+	"github.com/x-z7a/zoal-honeycomb/pkg/honeycomb"
+	"github.com/xairline/goplane/xplm/dataAccess"
 )
 
 // Mock logger to capture logs for testing
@@ -139,3 +144,290 @@ func TestUpdateLeds(t *testing.T) {
 	xpService.loadProfile(airplaneICAO)
 	xpService.updateLeds()
 }
+
+func TestGearLegLeds(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratio  float32
+		unsafe bool
+		green  bool
+		red    bool
+	}{
+		{"down and locked", 1, false, true, false},
+		{"in transit", 0.5, false, false, true},
+		{"up and locked", 0, false, false, false},
+		{"up while unsafe", 0, true, false, true},
+		{"down while unsafe", 1, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			green, red := gearLegLeds(tt.ratio, 0.99, 0.01, tt.unsafe)
+			assert.Equal(t, tt.green, green)
+			assert.Equal(t, tt.red, red)
+		})
+	}
+}
+
+func TestLoadProfileReadsGearLegs(t *testing.T) {
+	_, b, _, _ := runtime.Caller(0)
+	pluginPath := path.Join(path.Dir(b), "..", "..")
+
+	mockLogger := new(MockLogger)
+	mockLogger.On("Infof", mock.Anything, mock.Anything).Return()
+
+	xpService := &xplaneService{
+		Logger:     mockLogger,
+		pluginPath: pluginPath,
+	}
+
+	profile, err := xpService.loadProfile("B772")
+	assert.NoError(t, err)
+	assert.Nil(t, profile.Leds.GEAR.Nose)
+	assert.Equal(t, 3, profile.Leds.GEAR.Right.Index)
+	assert.Equal(t, "sim/flightmodel2/gear/deploy_ratio", profile.Leds.GEAR.Datarefs[0].DatarefStr)
+}
+
+func TestLedPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern *pkg.LEDPatternProfile
+		want    honeycomb.LEDPattern
+		wantErr bool
+	}{
+		{"no pattern", nil, honeycomb.LEDPattern{}, false},
+		{"steady", &pkg.LEDPatternProfile{Mode: "steady", Hz: 4}, honeycomb.LEDPattern{}, false},
+		{"blink default rate", &pkg.LEDPatternProfile{Mode: "blink"}, honeycomb.LEDPattern{BlinkHz: 2}, false},
+		{"flash", &pkg.LEDPatternProfile{Mode: "flash", Hz: 4, Duration: 1.5}, honeycomb.LEDPattern{BlinkHz: 4, Flash: 1500 * time.Millisecond}, false},
+		{"rate too high", &pkg.LEDPatternProfile{Mode: "blink", Hz: 50}, honeycomb.LEDPattern{}, true},
+		{"unknown mode", &pkg.LEDPatternProfile{Mode: "strobe"}, honeycomb.LEDPattern{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ledPattern(tt.pattern)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConditionResult(t *testing.T) {
+	tests := []struct {
+		condition     string
+		passed, total int
+		want          bool
+	}{
+		{"", 2, 2, true},
+		{"all", 1, 2, false},
+		{"any", 1, 2, true},
+		{"any", 0, 2, false},
+		{"none", 0, 2, true},
+		{"none", 1, 2, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, conditionResult(tt.condition, tt.passed, tt.total), "%s %d/%d", tt.condition, tt.passed, tt.total)
+	}
+	assert.False(t, isConditionLogicSupported("xor"))
+}
+
+func TestConditionCodeForArrayDatarefs(t *testing.T) {
+	threshold := float32(25)
+	oilPressure := []float32{40, 10, 40, 10, 0, 0, 0, 0}
+	env := map[string]interface{}{
+		"GetFloatArrayData": func(interface{}) []float32 { return oilPressure },
+		"myDataref":         nil,
+	}
+
+	tests := []struct {
+		name      string
+		condition pkg.DatarefCondition
+		want      bool
+	}{
+		{"single index", pkg.DatarefCondition{Index: 1}, true},
+		{"any element", pkg.DatarefCondition{Match: "any"}, true},
+		{"all elements", pkg.DatarefCondition{Match: "all"}, false},
+		{"all elements in range", pkg.DatarefCondition{Match: "all", Range: &pkg.IndexRange{From: 4, To: 7}}, true},
+		{"any element in range", pkg.DatarefCondition{Match: "any", Range: &pkg.IndexRange{From: 0, To: 0}}, false},
+		{"count reached", pkg.DatarefCondition{Match: "count", Count: 2, Range: &pkg.IndexRange{From: 0, To: 3}}, true},
+		{"count not reached", pkg.DatarefCondition{Match: "count", Count: 3, Range: &pkg.IndexRange{From: 0, To: 3}}, false},
+		{"range past the end", pkg.DatarefCondition{Match: "any", Range: &pkg.IndexRange{From: 3, To: 15}}, true},
+		{"all elements of a range out of bounds", pkg.DatarefCondition{Match: "all", Range: &pkg.IndexRange{From: 10, To: 15}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.condition.Operator = "<"
+			tt.condition.Threshold = &threshold
+			code, err := conditionCode(dataAccess.TypeFloatArray, &tt.condition)
+			assert.NoError(t, err)
+			program, err := expr.Compile(code, expr.Env(env))
+			assert.NoError(t, err)
+			output, err := expr.Run(program, env)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, output)
+		})
+	}
+}
+
+func TestConditionCodeRejectsInvalidMatches(t *testing.T) {
+	for _, condition := range []pkg.DatarefCondition{
+		{Match: "some"},
+		{Match: "count"},
+		{Match: "any", Range: &pkg.IndexRange{From: 3, To: 1}},
+	} {
+		_, err := conditionCode(dataAccess.TypeIntArray, &condition)
+		assert.Error(t, err, condition.Match)
+	}
+
+	_, err := conditionCode(dataAccess.TypeFloat, &pkg.DatarefCondition{Match: "any"})
+	assert.Error(t, err)
+}
+
+func TestConditionCodeForStringDatarefs(t *testing.T) {
+	env := map[string]interface{}{
+		"GetString": func(interface{}) string { return "ALT* LNAV" },
+		"myDataref": nil,
+	}
+
+	tests := []struct {
+		operator, text string
+		want           bool
+	}{
+		{"equals", "ALT* LNAV", true},
+		{"equals", "ALT*", false},
+		{"contains", "LNAV", true},
+		{"contains", "VNAV", false},
+		{"prefix", "ALT*", true},
+		{"prefix", "LNAV", false},
+		{"regex", `^ALT\*? `, true},
+		{"regex", `^VS`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operator+" "+tt.text, func(t *testing.T) {
+			code, err := conditionCode(dataAccess.TypeData, &pkg.DatarefCondition{Operator: tt.operator, Text: tt.text})
+			assert.NoError(t, err)
+			program, err := expr.Compile(code, expr.Env(env))
+			assert.NoError(t, err)
+			output, err := expr.Run(program, env)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, output)
+		})
+	}
+}
+
+func TestConditionCodeChecksStringOperators(t *testing.T) {
+	_, err := conditionCode(dataAccess.TypeFloat, &pkg.DatarefCondition{Operator: "contains", Text: "LNAV"})
+	assert.Error(t, err)
+
+	_, err = conditionCode(dataAccess.TypeData, &pkg.DatarefCondition{Operator: ">"})
+	assert.Error(t, err)
+
+	env := map[string]interface{}{
+		"GetString": func(interface{}) string { return "" },
+		"myDataref": nil,
+	}
+	code, err := conditionCode(dataAccess.TypeData, &pkg.DatarefCondition{Operator: "regex", Text: "(LNAV"})
+	assert.NoError(t, err)
+	_, err = expr.Compile(code, expr.Env(env))
+	assert.Error(t, err)
+}
+
+func TestEvaluateConditionWithHysteresis(t *testing.T) {
+	volts := float32(0)
+	env := map[string]interface{}{
+		"GetFloatData": func(interface{}) float32 { return volts },
+		"myDataref":    nil,
+	}
+	threshold := float32(24)
+	dataref := pkg.DatarefCondition{Operator: "<", Threshold: &threshold, Hysteresis: 1}
+	code, err := conditionCode(dataAccess.TypeFloat, &dataref)
+	assert.NoError(t, err)
+	dataref.Expr, err = expr.Compile(code, expr.Env(env))
+	assert.NoError(t, err)
+	code, err = holdConditionCode(dataAccess.TypeFloat, &dataref)
+	assert.NoError(t, err)
+	dataref.HoldExpr, err = expr.Compile(code, expr.Env(env))
+	assert.NoError(t, err)
+	dataref.Env = env
+
+	xpService := &xplaneService{Logger: new(MockLogger)}
+	condition := pkg.ConditionProfile{Datarefs: []pkg.DatarefCondition{dataref}}
+
+	for _, step := range []struct {
+		volts float32
+		want  bool
+	}{
+		{28, false},
+		{23.5, true},
+		{24.5, true}, // still within the band
+		{24.9, true},
+		{25.1, false},
+		{24.5, false}, // has to drop below the threshold again
+		{23.9, true},
+	} {
+		volts = step.volts
+		result, ok := xpService.evaluateCondition(&condition)
+		assert.True(t, ok)
+		assert.Equal(t, step.want, result, "%v volts", step.volts)
+	}
+
+	_, err = holdConditionCode(dataAccess.TypeFloat, &pkg.DatarefCondition{Operator: "==", Hysteresis: 1})
+	assert.Error(t, err)
+}
+
+func TestDelayedResult(t *testing.T) {
+	timer := &pkg.ConditionTimer{}
+	steps := []struct {
+		now    float64
+		result bool
+		want   bool
+	}{
+		{0, true, false},
+		{0.5, true, false},
+		{1.0, true, true}, // on after the 1s on delay
+		{1.1, false, true},
+		{1.2, true, true}, // flicker is ignored
+		{1.3, false, true},
+		{1.5, false, true},
+		{1.75, false, false}, // off after the 0.4s off delay
+		{1.8, true, false},
+		{1.9, false, false},
+	}
+	for _, step := range steps {
+		assert.Equal(t, step.want, delayedResult(timer, step.result, step.now, 1, 0.4), "at %v", step.now)
+	}
+}
+
+func TestAggregateValues(t *testing.T) {
+	values := []float64{0, 4, -2, 6}
+	tests := []struct {
+		aggregate string
+		want      float64
+	}{
+		{"", 0},
+		{"min", -2},
+		{"max", 6},
+		{"sum", 8},
+		{"avg", 2},
+		{"first_non_zero", 4},
+	}
+	for _, tt := range tests {
+		got, ok := aggregateValues(tt.aggregate, values)
+		assert.True(t, ok, tt.aggregate)
+		assert.Equal(t, tt.want, got, tt.aggregate)
+	}
+
+	_, ok := aggregateValues("max", nil)
+	assert.False(t, ok)
+	assert.False(t, isAggregateSupported("median"))
+}
+
+func TestIndexRange(t *testing.T) {
+	values := []float64{1, 2, 3, 4}
+	assert.Equal(t, values, indexRange(values, nil))
+	assert.Equal(t, []float64{2, 3}, indexRange(values, &pkg.IndexRange{From: 1, To: 2}))
+	assert.Equal(t, []float64{3, 4}, indexRange(values, &pkg.IndexRange{From: 2, To: 7}))
+	assert.Empty(t, indexRange(values, &pkg.IndexRange{From: 5, To: 7}))
+}